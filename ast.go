@@ -4,29 +4,40 @@ package kiwi
 
 import (
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
+	"math"
 	"strconv"
 	"strings"
 )
 
-type AST struct{ ast.Expr }
+type AST struct {
+	node
+	strength *Strength
+	src      string
+}
 
 func ParseExpr(a ...interface{}) (*AST, error) {
-	expr, err := parser.ParseExpr(fmt.Sprint(a...))
-	if err != nil {
-		return nil, err
-	}
-	return &AST{expr}, nil
+	return newParser(fmt.Sprint(a...)).parseStatement()
 }
 
 func ParseConstraint(x string, vars []*Variable, options ...ConstraintOption) (*Constraint, error) {
+	return ParseConstraintIn(x, NamesOf(vars), options...)
+}
+
+/*
+ParseConstraintIn parses a constraint resolving the names in the text
+against the given namespace.
+
+Returns
+
+	UnknownVariableName
+A name in the text is not defined in the namespace.
+*/
+func ParseConstraintIn(x string, ns Namespace, options ...ConstraintOption) (*Constraint, error) {
 	expr, err := ParseExpr(x)
 	if err != nil {
 		return nil, err
 	}
-	cnst, err := expr.NewConstraint(vars, options...)
+	cnst, err := expr.NewConstraintIn(ns, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -35,153 +46,438 @@ func ParseConstraint(x string, vars []*Variable, options ...ConstraintOption) (*
 
 func (a AST) String() string {
 	var f strings.Builder
-	var walk func(expr ast.Expr)
-	walk = func(expr ast.Expr) {
+	var walk func(expr node)
+	walk = func(expr node) {
 		switch e := expr.(type) {
-		case *ast.BinaryExpr:
-			walk(e.X)
-			fmt.Fprintf(&f, " %v ", e.Op)
-			walk(e.Y)
-		case *ast.Ident:
-			if e.Obj == nil {
-				fmt.Fprintf(&f, "%v", e.Name)
-			} else {
-				fmt.Fprintf(&f, "%v %v", e.Name, e.Obj)
+		case *binaryExpr:
+			walk(e.x)
+			fmt.Fprintf(&f, " %v ", e.op)
+			walk(e.y)
+		case *unaryExpr:
+			fmt.Fprintf(&f, "%v", e.op)
+			walk(e.x)
+		case *ident:
+			fmt.Fprintf(&f, "%v", e.name)
+		case *basicLit:
+			fmt.Fprintf(&f, "%v(%v)", e.kind, e.value)
+		case *callExpr:
+			fmt.Fprintf(&f, "%v(", e.name)
+			for i, arg := range e.args {
+				if i > 0 {
+					fmt.Fprint(&f, ", ")
+				}
+				walk(arg)
 			}
-		case *ast.BasicLit:
-			fmt.Fprintf(&f, "%v(%v)", e.Kind, e.Value)
-		case *ast.ParenExpr:
+			fmt.Fprint(&f, ")")
+		case *parenExpr:
 			fmt.Fprint(&f, "(")
-			walk(e.X)
+			walk(e.x)
 			fmt.Fprint(&f, ")")
 		default:
 			fmt.Fprintf(&f, "%#v", e)
 		}
 	}
-	walk(a.Expr)
+	walk(a.node)
+	if a.strength != nil {
+		fmt.Fprintf(&f, " | %v", *a.strength)
+	}
 	return f.String()
 }
 
 func (a AST) TechString() string {
 	const PAD = 8
 	var f strings.Builder
-	var walk func(expr ast.Expr, level int)
-	walk = func(expr ast.Expr, level int) {
+	var walk func(expr node, level int)
+	walk = func(expr node, level int) {
 		padding := fmt.Sprintf("%*v", level, "")
 		paddingnext := fmt.Sprintf("%*v", level+PAD, "")
 		switch e := expr.(type) {
-		case *ast.BinaryExpr:
-			fmt.Fprintln(&f, padding+"(*ast.BinaryExpr:")
-			walk(e.X, level+PAD)
-			fmt.Fprintf(&f, paddingnext+"%v\n", e.Op)
-			walk(e.Y, level+PAD)
+		case *binaryExpr:
+			fmt.Fprintln(&f, padding+"(BinaryExpr:")
+			walk(e.x, level+PAD)
+			fmt.Fprintf(&f, paddingnext+"%v\n", e.op)
+			walk(e.y, level+PAD)
+			fmt.Fprintln(&f, padding+")")
+		case *unaryExpr:
+			fmt.Fprintln(&f, padding+"(UnaryExpr:")
+			fmt.Fprintf(&f, paddingnext+"%v\n", e.op)
+			walk(e.x, level+PAD)
 			fmt.Fprintln(&f, padding+")")
-		case *ast.Ident:
-			fmt.Fprint(&f, padding+"(*ast.Ident: ")
-			if e.Obj == nil {
-				fmt.Fprintf(&f, "%v", e.Name)
-			} else {
-				fmt.Fprintf(&f, "%v %v", e.Name, e.Obj)
+		case *ident:
+			fmt.Fprintf(&f, padding+"(Ident: %v)\n", e.name)
+		case *basicLit:
+			fmt.Fprintf(&f, padding+"(BasicLit: %v %v)\n", e.kind, e.value)
+		case *callExpr:
+			fmt.Fprintf(&f, padding+"(CallExpr: %v\n", e.name)
+			for _, arg := range e.args {
+				walk(arg, level+PAD)
 			}
-			fmt.Fprintln(&f, ")")
-		case *ast.BasicLit:
-			fmt.Fprint(&f, padding+"(*ast.BasicLit: ")
-			fmt.Fprintf(&f, "%v %v", e.Kind, e.Value)
-			fmt.Fprintln(&f, ")")
-		case *ast.ParenExpr:
-			fmt.Fprintln(&f, padding+"(*ast.ParenExpr:")
-			walk(e.X, level+PAD)
+			fmt.Fprintln(&f, padding+")")
+		case *parenExpr:
+			fmt.Fprintln(&f, padding+"(ParenExpr:")
+			walk(e.x, level+PAD)
 			fmt.Fprintln(&f, padding+")")
 		default:
 			fmt.Fprintf(&f, "%#v\n", e)
 		}
 	}
-	walk(a.Expr, 0)
+	walk(a.node, 0)
 	return f.String()
 }
 
+/*
+NewConstraint evaluates the AST into a single constraint.
+
+A strength annotation in the text, e.g. `x <= 10 | strong`, takes
+precedence over a strength given as option.
+
+An expression without comparison operator, e.g. `x + y`, evaluates to
+the constraint `x + y == 0`. A chained comparison like `0 <= x <= 100`
+or an expression using the functions min, max or abs yields more than
+one constraint and must be evaluated with NewConstraints instead.
+*/
 func (a AST) NewConstraint(vars []*Variable, options ...ConstraintOption) (*Constraint, error) {
-	varmap := make(map[string]*Variable)
-	for _, v := range vars {
-		varmap[v.Name] = v
+	return a.NewConstraintIn(NamesOf(vars), options...)
+}
+
+/*
+NewConstraintIn is like NewConstraint but resolves names against the given
+namespace.
+*/
+func (a AST) NewConstraintIn(ns Namespace, options ...ConstraintOption) (*Constraint, error) {
+	cns, err := a.NewConstraintsIn(ns, options...)
+	if err != nil {
+		return nil, err
 	}
-	var evaluate func(expr ast.Expr) (evaluation, error)
-	evaluate = func(expr ast.Expr) (evaluation, error) {
+	if len(cns) != 1 {
+		return nil, a.errorAt(a.Pos(), "", EvaluationError("expression yields ", len(cns), " constraints"))
+	}
+	return cns[0], nil
+}
+
+/*
+NewConstraints evaluates the AST into constraints, one for every
+comparison operator in a chained comparison. E.g. `0 <= x <= 100`
+evaluates to the constraints `0 <= x` and `x <= 100`.
+
+The functions min, max and abs are evaluated directly when all their
+arguments are constant. Otherwise every call introduces an auxiliary
+variable, see Min, Max and Abs, and the constraints defining it are
+added to the constraints of the comparison. The auxiliary is only pulled
+towards its arguments when the comparison needs it, so e.g.
+`w >= max(a, b)` evaluates to exactly `w >= m`, `m >= a` and `m >= b`.
+The pulls are as strong as the comparison, e.g. `w == max(a, b) | strong`,
+and can't be required, so a required comparison that needs them returns
+an error.
+*/
+func (a AST) NewConstraints(vars []*Variable, options ...ConstraintOption) ([]*Constraint, error) {
+	return a.NewConstraintsIn(NamesOf(vars), options...)
+}
+
+/*
+NewConstraintsIn is like NewConstraints but resolves names against the
+given namespace.
+
+When the namespace is a ValueNamespace, names of constants evaluate to
+their value and names of parameters are bound to the constraints they
+appear in, see Parameter.
+*/
+func (a AST) NewConstraintsIn(ns Namespace, options ...ConstraintOption) ([]*Constraint, error) {
+	var auxiliaries []*auxiliary
+	params := map[*Variable]*Parameter{}
+	values, _ := ns.(ValueNamespace)
+	var evaluate func(expr node) (evaluation, error)
+	evaluate = func(expr node) (evaluation, error) {
 		switch e := expr.(type) {
-		case *ast.BinaryExpr:
-			lhs, err := evaluate(e.X)
+		case *binaryExpr:
+			lhs, err := evaluate(e.x)
 			if err != nil {
 				return nil, err
 			}
-			rhs, err := evaluate(e.Y)
+			rhs, err := evaluate(e.y)
 			if err != nil {
 				return nil, err
 			}
-			switch e.Op {
-			case token.ADD:
-				return lhs.add(rhs)
-			case token.SUB:
-				return lhs.sub(rhs)
-			case token.MUL:
-				return lhs.mul(rhs)
-			case token.QUO:
-				return lhs.div(rhs)
-			case token.EQL:
-				return lhs.eql(rhs)
-			case token.LEQ:
-				return lhs.leq(rhs)
-			case token.GEQ:
-				return lhs.geq(rhs)
+			var evl evaluation
+			switch e.op {
+			case tokADD:
+				evl, err = lhs.add(rhs)
+			case tokSUB:
+				evl, err = lhs.sub(rhs)
+			case tokMUL:
+				evl, err = lhs.mul(rhs)
+			case tokQUO:
+				evl, err = lhs.div(rhs)
+			case tokEQL:
+				evl, err = lhs.eql(rhs)
+			case tokLEQ:
+				evl, err = lhs.leq(rhs)
+			case tokGEQ:
+				evl, err = lhs.geq(rhs)
 			default:
-				return nil, EvaluationError("operator ", e.Op, " not supported")
+				err = EvaluationError("operator ", e.op, " not supported")
 			}
-		case *ast.Ident:
-			v, present := varmap[e.Name]
-			if !present {
-				return nil, UnknownVariableName{e.Name}
+			if err != nil {
+				return nil, a.errorAt(e.opPos, e.op.String(), err)
 			}
-			return vareval{v}, nil
-		case *ast.BasicLit:
-			switch e.Kind {
-			case token.FLOAT, token.INT:
-				fv, err := strconv.ParseFloat(e.Value, 64)
+			return evl, nil
+		case *unaryExpr:
+			x, err := evaluate(e.x)
+			if err != nil {
+				return nil, err
+			}
+			if e.op == tokADD {
+				return x, nil
+			}
+			evl, err := liteval{-1}.mul(x)
+			if err != nil {
+				return nil, a.errorAt(e.opPos, e.op.String(), err)
+			}
+			return evl, nil
+		case *callExpr:
+			var args []Expression
+			constant := true
+			for _, arg := range e.args {
+				x, err := evaluate(arg)
 				if err != nil {
 					return nil, err
 				}
-				return liteval{fv}, nil
+				expr, ok := asExpression(x)
+				if !ok {
+					return nil, a.errorAt(arg.Pos(), "", EvaluationError("argument of ", e.name, " is not an expression"))
+				}
+				args = append(args, expr)
+				constant = constant && expr.IsConstant()
+			}
+			name := a.src[e.namePos : e.rparen+1]
+			switch e.name {
+			case "max", "min":
+				if constant {
+					value := args[0].Constant
+					for _, arg := range args[1:] {
+						if (e.name == "max") == (arg.Constant > value) {
+							value = arg.Constant
+						}
+					}
+					return liteval{value}, nil
+				}
+				aux := newAuxiliary(name, e.name == "max", args)
+				aux.pos = e.namePos
+				auxiliaries = append(auxiliaries, aux)
+				return vareval{aux.variable}, nil
+			case "abs":
+				if len(args) != 1 {
+					return nil, a.errorAt(e.namePos, e.name, EvaluationError("abs takes 1 argument, got ", len(args)))
+				}
+				if constant {
+					return liteval{math.Abs(args[0].Constant)}, nil
+				}
+				aux := newAuxiliary(name, true, []Expression{args[0], args[0].Negate()})
+				aux.pos = e.namePos
+				auxiliaries = append(auxiliaries, aux)
+				return vareval{aux.variable}, nil
+			}
+			return nil, a.errorAt(e.namePos, e.name, EvaluationError("unknown function ", e.name))
+		case *ident:
+			if values != nil {
+				if value, present := values.LookupConst(e.name); present {
+					return liteval{value}, nil
+				}
+				if p, present := values.LookupParam(e.name); present {
+					params[p.variable] = p
+					return vareval{p.variable}, nil
+				}
+			}
+			v, present := ns.Lookup(e.name)
+			if !present {
+				return nil, a.errorAt(e.namePos, e.name, UnknownVariableName{e.name})
 			}
-			return nil, SyntaxError
-		case *ast.ParenExpr:
-			ex, err := evaluate(e.X)
+			return vareval{v}, nil
+		case *basicLit:
+			fv, err := strconv.ParseFloat(e.value, 64)
+			if err != nil {
+				return nil, a.errorAt(e.valuePos, e.value, err)
+			}
+			return liteval{fv}, nil
+		case *parenExpr:
+			return evaluate(e.x)
+		}
+		return nil, a.errorAt(expr.Pos(), "", EvaluationError("unexpected node ", expr))
+	}
+
+	// Split a chained comparison `a op b op c` into `a op b` and `b op c`.
+	var operands []node
+	var operators []*binaryExpr
+	expr := a.node
+	for {
+		b, ok := expr.(*binaryExpr)
+		if !ok || !b.op.isComparison() {
+			break
+		}
+		operands = append([]node{b.y}, operands...)
+		operators = append([]*binaryExpr{b}, operators...)
+		expr = b.x
+	}
+	operands = append([]node{expr}, operands...)
+
+	var cnss []*Constraint
+	if len(operators) == 0 {
+		evl, err := evaluate(expr)
+		if err != nil {
+			return nil, err
+		}
+		cns, err := evl.eql(liteval{0})
+		if err != nil {
+			return nil, a.errorAt(expr.Pos(), "", err)
+		}
+		cnss = append(cnss, cns.(constreval).Constraint)
+	}
+	// Evaluate every operand once, so a function in a middle operand is
+	// the same auxiliary variable in both of its comparisons.
+	evls := make([]evaluation, len(operands))
+	if len(operators) > 0 {
+		for i, operand := range operands {
+			evl, err := evaluate(operand)
 			if err != nil {
 				return nil, err
 			}
-			return ex, nil
+			evls[i] = evl
 		}
-		return nil, nil
 	}
-	evl, err := evaluate(a.Expr)
-	if err != nil {
-		return nil, err
+	for i, op := range operators {
+		lhs, rhs := fresh(evls[i]), fresh(evls[i+1])
+		var evl evaluation
+		var err error
+		switch op.op {
+		case tokEQL:
+			evl, err = lhs.eql(rhs)
+		case tokLEQ:
+			evl, err = lhs.leq(rhs)
+		case tokGEQ:
+			evl, err = lhs.geq(rhs)
+		}
+		if err != nil {
+			return nil, a.errorAt(op.opPos, op.op.String(), err)
+		}
+		cns, ok := evl.(constreval)
+		if !ok {
+			return nil, a.errorAt(op.opPos, op.op.String(), EvaluationError("comparison does not yield a constraint"))
+		}
+		cnss = append(cnss, cns.Constraint)
+	}
+	if a.strength != nil {
+		options = append(options, WithStrength(*a.strength))
+	}
+	for _, cns := range cnss {
+		cns.ApplyOptions(options...)
+	}
+
+	// Add the constraints defining the auxiliary variables of functions.
+	// An auxiliary is only pulled towards its arguments when one of its
+	// uses needs it, as strongly as the strongest of those uses. The
+	// bounds of a function are as strong as its strongest use where they
+	// are used by a function in its arguments. Outer functions are
+	// created after the functions in their arguments, so they are
+	// handled first.
+	strengths := make(map[*Constraint]Strength, len(cnss))
+	for _, cns := range cnss {
+		strengths[cns] = cns.Strength
+	}
+	for i := len(auxiliaries) - 1; i >= 0; i-- {
+		aux := auxiliaries[i]
+		used, pulled := OPTIONAL, OPTIONAL
+		needed := false
+		for _, cns := range cnss {
+			if aux.uses(cns) && strengths[cns] > used {
+				used = strengths[cns]
+			}
+			if aux.needsPulls(cns) {
+				needed = true
+				if strengths[cns] > pulled {
+					pulled = strengths[cns]
+				}
+			}
+		}
+		for _, bound := range aux.bounds {
+			strengths[bound] = used
+		}
+		cnss = append(cnss, aux.bounds...)
+		if needed {
+			if pulled >= REQUIRED {
+				name := aux.variable.Name
+				return nil, a.errorAt(aux.pos, name, EvaluationError(name, " cannot be enforced by a required constraint, give the constraint a strength"))
+			}
+			aux.pull(pulled)
+			for _, pull := range aux.pulls {
+				strengths[pull] = pulled
+			}
+			cnss = append(cnss, aux.pulls...)
+		}
+	}
+
+	// Parameters are evaluated as placeholder variables. Replace their
+	// terms by their current value and bind them to the constraints.
+	if len(params) > 0 {
+		for _, cns := range cnss {
+			var terms []Term
+			for _, t := range cns.Expression.Terms {
+				if p, present := params[t.Variable]; present {
+					if !NearZero(t.Coefficient) {
+						p.bind(cns, t.Coefficient)
+					}
+					continue
+				}
+				terms = append(terms, t)
+			}
+			cns.Expression.Terms = terms
+		}
+	}
+	return cnss, nil
+}
+
+/*
+fresh returns the evaluation with a copy of the terms of an expression,
+so an operand that is used in two comparisons doesn't share its terms
+between the constraints.
+*/
+func fresh(evl evaluation) evaluation {
+	if e, ok := evl.(expreval); ok {
+		return expreval{Expression{append([]Term(nil), e.Expression.Terms...), e.Expression.Constant}}
 	}
-	var cns *Constraint
+	return evl
+}
+
+/*
+asExpression converts the evaluation of a linear expression into an
+expression.
+*/
+func asExpression(evl evaluation) (Expression, bool) {
 	switch e := evl.(type) {
-	case constreval:
-		cns = e.Constraint
-	case expreval:
-		cns = e.Expression.EqualsConstant(0)
-	case termeval:
-		cns = e.Term.EqualsConstant(0)
+	case liteval:
+		return Expression{Constant: e.Value}, true
 	case vareval:
-		cns = e.Variable.EqualsConstant(0)
+		return e.Variable.AddConstant(0), true
+	case termeval:
+		return e.Term.AddConstant(0), true
+	case expreval:
+		return e.Expression, true
 	}
-	if cns == nil {
-		return nil, EvaluationError("NewConstraint")
+	return Expression{}, false
+}
+
+/*
+errorAt returns the error annotated with the position in the source text
+of the token at the given offset. Errors that already carry a position
+are returned as is.
+*/
+func (a AST) errorAt(offset int, token string, err error) error {
+	if _, ok := err.(ParseError); ok {
+		return err
 	}
-	for _, opt := range options {
-		opt(cns)
+	if offset > len(a.src) {
+		offset = len(a.src)
 	}
-	return cns, nil
+	return newParseError(a.src, offset, token, "", err)
 }
 
 type evaluation interface {
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+/*
+Box is a rectangle whose position and size are solved for. The right and
+bottom edges and the center are derived from the position and size.
+*/
+type Box struct {
+	Left, Top, Width, Height *Variable
+}
+
+/*
+NewBox returns a box with variables named after the box, e.g.
+`button.left` and `button.width`.
+*/
+func NewBox(name string) *Box {
+	return &Box{
+		Left:   NewVariable(name + ".left"),
+		Top:    NewVariable(name + ".top"),
+		Width:  NewVariable(name + ".width"),
+		Height: NewVariable(name + ".height"),
+	}
+}
+
+// Variables returns the left, top, width and height variables of the box.
+func (b *Box) Variables() []*Variable {
+	return []*Variable{b.Left, b.Top, b.Width, b.Height}
+}
+
+// Right returns the expression `left + width`.
+func (b *Box) Right() Expression {
+	return b.Left.AddVariable(b.Width)
+}
+
+// Bottom returns the expression `top + height`.
+func (b *Box) Bottom() Expression {
+	return b.Top.AddVariable(b.Height)
+}
+
+// CenterX returns the expression `left + width / 2`.
+func (b *Box) CenterX() Expression {
+	return b.Left.AddTerm(b.Width.Divide(2))
+}
+
+// CenterY returns the expression `top + height / 2`.
+func (b *Box) CenterY() Expression {
+	return b.Top.AddTerm(b.Height.Divide(2))
+}
+
+/*
+Edge is a set of edges of a box, e.g. `EdgeLeft | EdgeRight`. The
+centers count as edges, so boxes can be centered on each other.
+*/
+type Edge int
+
+const (
+	EdgeLeft Edge = 1 << iota
+	EdgeTop
+	EdgeRight
+	EdgeBottom
+	EdgeCenterX
+	EdgeCenterY
+)
+
+func (b *Box) edges(edges Edge) []Expression {
+	var exprs []Expression
+	for _, edge := range []struct {
+		Edge
+		expr func() Expression
+	}{
+		{EdgeLeft, func() Expression { return b.Left.AddConstant(0) }},
+		{EdgeTop, func() Expression { return b.Top.AddConstant(0) }},
+		{EdgeRight, b.Right},
+		{EdgeBottom, b.Bottom},
+		{EdgeCenterX, b.CenterX},
+		{EdgeCenterY, b.CenterY},
+	} {
+		if edges&edge.Edge != 0 {
+			exprs = append(exprs, edge.expr())
+		}
+	}
+	return exprs
+}
+
+/*
+Align returns a constraint for every given edge that puts that edge of
+the box at the same position as the edge of the other box.
+*/
+func (b *Box) Align(other *Box, edges Edge, options ...ConstraintOption) []*Constraint {
+	var cns []*Constraint
+	others := other.edges(edges)
+	for i, expr := range b.edges(edges) {
+		c := expr.EqualsExpression(others[i])
+		c.ApplyOptions(options...)
+		cns = append(cns, c)
+	}
+	return cns
+}
+
+// SameSize returns the constraints that give the box the size of the other box.
+func (b *Box) SameSize(other *Box, options ...ConstraintOption) []*Constraint {
+	cns := []*Constraint{b.Width.EqualsVariable(other.Width), b.Height.EqualsVariable(other.Height)}
+	for _, c := range cns {
+		c.ApplyOptions(options...)
+	}
+	return cns
+}
+
+/*
+Inside returns the constraints that keep the box inside the outer box
+with at least the padding between their edges.
+*/
+func (b *Box) Inside(outer *Box, padding float64, options ...ConstraintOption) []*Constraint {
+	cns := []*Constraint{
+		b.Left.GreaterThanOrEqualsExpression(outer.Left.AddConstant(padding)),
+		b.Top.GreaterThanOrEqualsExpression(outer.Top.AddConstant(padding)),
+		b.Right().LessThanOrEqualsExpression(outer.Right().AddConstant(-padding)),
+		b.Bottom().LessThanOrEqualsExpression(outer.Bottom().AddConstant(-padding)),
+	}
+	for _, c := range cns {
+		c.ApplyOptions(options...)
+	}
+	return cns
+}
+
+// Contains returns the constraints that keep the other box inside the box.
+func (b *Box) Contains(other *Box, options ...ConstraintOption) []*Constraint {
+	return other.Inside(b, 0, options...)
+}
+
+/*
+AddConstraints adds all the constraints to the solver. When one of the
+constraints can't be added, the constraints added before it are removed
+again and the error of that constraint is returned.
+*/
+func (s *Solver) AddConstraints(constraints []*Constraint, options ...ConstraintOption) error {
+	for i, c := range constraints {
+		if err := s.AddConstraint(c, options...); err != nil {
+			for _, added := range constraints[:i] {
+				s.RemoveConstraint(added)
+			}
+			return err
+		}
+	}
+	return nil
+}
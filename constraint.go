@@ -11,6 +11,8 @@ type Constraint struct {
 	Expression Expression
 	Operator   Operator
 	Strength   Strength
+
+	bindings []*binding
 }
 
 type ConstraintOption func(*Constraint)
@@ -39,7 +41,7 @@ func NewConstraint(expr Expression, op Operator, options ...ConstraintOption) *C
 		}
 	}
 	expr.Terms = expr.Terms[:len(expr.Terms)-collapsed]
-	cns := &Constraint{expr, op, REQUIRED}
+	cns := &Constraint{Expression: expr, Operator: op, Strength: REQUIRED}
 	cns.ApplyOptions(options...)
 	return cns
 }
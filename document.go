@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"bufio"
+	"io"
+)
+
+// LabeledConstraint is a constraint parsed from a document.
+type LabeledConstraint struct {
+	Label string
+	Line  int
+	*Constraint
+}
+
+/*
+ParseConstraints parses a document of constraints, one per line, with
+names resolved against the given namespace.
+
+Lines may be blank, hold only a comment starting with `#` or `//`, or
+hold a constraint with an optional label, as in:
+
+	# spacing
+	header_gap: header.bottom + 8 == body.top
+	0 <= body.left <= 20 | strong
+
+A chained comparison yields a constraint for every comparison operator,
+all with the label and line of the statement. The given options are
+applied to every constraint before its strength annotation.
+
+Returns
+
+	ParseErrors
+One or more lines failed to parse or evaluate. The errors cover all
+the lines that failed, not just the first one.
+*/
+func ParseConstraints(r io.Reader, ns Namespace, options ...ConstraintOption) ([]LabeledConstraint, error) {
+	var cnss []LabeledConstraint
+	err := parseLines(r, func(p *parser, label string, line int) error {
+		a, err := p.parseStatement()
+		if err != nil {
+			return err
+		}
+		cs, err := a.NewConstraintsIn(ns, options...)
+		if err != nil {
+			return err
+		}
+		for _, c := range cs {
+			cnss = append(cnss, LabeledConstraint{label, line, c})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cnss, nil
+}
+
+/*
+parseLines calls parse for every line of the document that is not blank
+or a comment, with the parser positioned after the optional label.
+
+Returns
+
+	ParseErrors
+The errors returned by parse and the duplicate labels, for all lines.
+*/
+func parseLines(r io.Reader, parse func(p *parser, label string, line int) error) error {
+	var errs ParseErrors
+	scanner := bufio.NewScanner(r)
+	labels := make(map[string]int)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		p := newParser(text)
+		if p.tok == tokEOF {
+			continue
+		}
+		labelPos := p.pos
+		label := p.parseLabel()
+		var err error
+		if first, present := labels[label]; label != "" && present {
+			err = newParseError(text, labelPos, label, "", DuplicateLabel{label, first})
+		} else {
+			if label != "" {
+				labels[label] = line
+			}
+			err = parse(p, label, line)
+		}
+		if err != nil {
+			pe, ok := err.(ParseError)
+			if !ok {
+				pe = newParseError(text, 0, "", "", err)
+			}
+			pe.Line = line
+			errs = append(errs, pe)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+WriteDOT writes a Graphviz DOT graph of the constraints. The variables
+are ellipses labeled with their name and current value, every constraint
+is a box connected to the variables it uses, so a constraint between
+more than two variables shows up as a hyperedge.
+
+Constraints are colored by their base strength: required constraints are
+black, strong ones red, medium ones orange, weak ones blue and optional
+ones gray. A constraint that is violated by the current values of its
+variables is filled in red, the given edit variables are filled in gold.
+
+Render the graph with e.g. `dot -Tsvg -o constraints.svg`.
+*/
+func WriteDOT(w io.Writer, constraints []*Constraint, edits ...*Variable) error {
+	ids := make(map[*Variable]string)
+	var vars []*Variable
+	for _, c := range constraints {
+		for _, t := range c.Expression.Terms {
+			if _, present := ids[t.Variable]; !present {
+				ids[t.Variable] = fmt.Sprintf("v%d", len(ids)+1)
+				vars = append(vars, t.Variable)
+			}
+		}
+	}
+	for _, v := range edits {
+		if _, present := ids[v]; !present {
+			ids[v] = fmt.Sprintf("v%d", len(ids)+1)
+			vars = append(vars, v)
+		}
+	}
+	edit := make(map[*Variable]bool, len(edits))
+	for _, v := range edits {
+		edit[v] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("graph kiwi {\n")
+	b.WriteString("\tnode [shape=ellipse];\n")
+	for _, v := range vars {
+		attrs := "label=" + dotQuote(v.Name+" = "+formatNumber(v.Value))
+		if edit[v] {
+			attrs += ", style=filled, fillcolor=gold"
+		}
+		b.WriteString("\t" + ids[v] + " [" + attrs + "];\n")
+	}
+	for i, c := range constraints {
+		id := fmt.Sprintf("c%d", i+1)
+		color := dotColor(c.Strength)
+		attrs := "shape=box, fontsize=10, color=" + color + ", label=" + dotQuote(FormatConstraint(c))
+		if violated(c) {
+			attrs += ", style=filled, fillcolor=\"#ffc0c0\", penwidth=2"
+		}
+		b.WriteString("\t" + id + " [" + attrs + "];\n")
+		linked := make(map[*Variable]bool)
+		for _, t := range c.Expression.Terms {
+			if !linked[t.Variable] {
+				linked[t.Variable] = true
+				b.WriteString("\t" + id + " -- " + ids[t.Variable] + " [color=" + color + "];\n")
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+/*
+WriteDOT writes a Graphviz DOT graph of the constraints and stays of
+the solver, with its edit variables highlighted. See WriteDOT.
+*/
+func (s *Solver) WriteDOT(w io.Writer) error {
+	constraints := s.Constraints()
+	for _, stay := range s.Stays() {
+		constraints = append(constraints, s.stays[stay.Variable])
+	}
+	var edits []*Variable
+	for _, edit := range s.EditVariables() {
+		edits = append(edits, edit.Variable)
+	}
+	return WriteDOT(w, constraints, edits...)
+}
+
+func dotColor(strength Strength) string {
+	switch strength.Base() {
+	case REQUIRED:
+		return "black"
+	case STRONG:
+		return "red"
+	case MEDIUM:
+		return "orange"
+	case WEAK:
+		return "blue"
+	default:
+		return "gray"
+	}
+}
+
+func dotQuote(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+/*
+violated tests whether the constraint is violated by the current values
+of its variables.
+*/
+func violated(c *Constraint) bool {
+	value := c.Expression.GetValue()
+	switch c.Operator {
+	case LE:
+		return value > 0 && !NearZero(value)
+	case GE:
+		return value < 0 && !NearZero(value)
+	default:
+		return !NearZero(value)
+	}
+}
@@ -7,3 +7,129 @@ type edit struct {
 	constraint *Constraint
 	constant   float64
 }
+
+// EditVariable describes an edit variable and the value suggested for it.
+type EditVariable struct {
+	Variable *Variable
+	Strength Strength
+	Value    float64
+}
+
+/*
+EditSession groups a set of edit variables that are edited together.
+
+An edit session is started by calling `BeginEdit` on a solver and is
+ended by calling `End` on the session. While the session is active,
+values can be suggested for the variables in the session. When the
+session ends, all its edit variables are removed from the solver and
+the stay constraints are updated exactly once.
+*/
+type EditSession struct {
+	solver    *Solver
+	variables []*Variable
+	members   map[*Variable]bool
+}
+
+/*
+BeginEdit starts an edit session by adding the given variables as edit
+variables to the solver, all with the same strength.
+
+When no strength option is given the edit variables will be created
+with STRONG strength.
+
+Returns
+
+	DuplicateEditVariable
+One of the given variables already is an edit variable. None of the
+variables will have been added to the solver.
+	BadRequiredStrength
+The given strength is >= required.
+*/
+func (s *Solver) BeginEdit(variables []*Variable, options ...ConstraintOption) (*EditSession, error) {
+	session := &EditSession{solver: s, members: make(map[*Variable]bool)}
+	for _, v := range variables {
+		if session.members[v] {
+			continue
+		}
+		if err := s.AddEditVariable(v, options...); err != nil {
+			for _, added := range session.variables {
+				s.removeEditVariable(added)
+			}
+			return nil, err
+		}
+		session.variables = append(session.variables, v)
+		session.members[v] = true
+	}
+	return session, nil
+}
+
+/*
+Variables returns the edit variables of the session in the order they
+were given to BeginEdit.
+*/
+func (e *EditSession) Variables() []*Variable {
+	return append([]*Variable(nil), e.variables...)
+}
+
+/*
+SuggestValue suggests a value for one of the edit variables of the session.
+
+Returns
+
+	UnknownEditVariable
+The given variable is not part of the session or the session has ended.
+*/
+func (e *EditSession) SuggestValue(variable *Variable, value float64) error {
+	if !e.members[variable] {
+		return UnknownEditVariable{variable}
+	}
+	return e.solver.SuggestValue(variable, value)
+}
+
+/*
+SuggestValues suggests values for a number of edit variables of the
+session at once, running a single dual optimization.
+
+Returns
+
+	UnknownEditVariable
+One of the given variables is not part of the session or the session
+has ended. No suggestions are applied in that case.
+*/
+func (e *EditSession) SuggestValues(values map[*Variable]float64) error {
+	for variable := range values {
+		if !e.members[variable] {
+			return UnknownEditVariable{variable}
+		}
+	}
+	return e.solver.SuggestValues(values)
+}
+
+/*
+End ends the edit session.
+
+The values of the variables are updated and the stay constraints are
+updated once to commit the changes made during the session. Then all
+edit variables of the session are removed from the solver. Calling End
+on a session that has already ended does nothing.
+
+Returns
+
+	StayErrors
+One or more stays could not be updated and have been removed.
+*/
+func (e *EditSession) End() error {
+	if len(e.variables) == 0 {
+		return nil
+	}
+	e.solver.UpdateVariables()
+	err := e.solver.UpdateStays()
+	for _, v := range e.variables {
+		if rerr := e.solver.removeEditVariable(v); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	e.variables = nil
+	e.members = map[*Variable]bool{}
+	return err
+}
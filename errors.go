@@ -4,7 +4,8 @@ package kiwi
 
 import (
 	"fmt"
-	"runtime"
+	"strings"
+	"unicode/utf8"
 )
 
 type Error string
@@ -19,8 +20,7 @@ const FailedToFindLeavingRow = Error("Failed to find Leaving Row")
 const SyntaxError = Error("Syntax Error")
 
 func EvaluationError(a ...interface{}) Error {
-	_, filename, line, _ := runtime.Caller(1)
-	return Error(fmt.Sprintf("Evaluation Error @ %s:%d (%s)", filename, line, fmt.Sprint(a...)))
+	return Error("Evaluation Error: " + fmt.Sprint(a...))
 }
 
 type DuplicateConstraint struct{ *Constraint }
@@ -68,5 +68,142 @@ func (e DuplicateStayVariable) Error() string {
 type UnknownVariableName struct{ Name string }
 
 func (e UnknownVariableName) Error() string {
-	return fmt.Sprintf("Unkown Variable Name: %q", e.Name)
+	return fmt.Sprintf("Unknown Variable Name: %q", e.Name)
+}
+
+type InvalidVariableName struct{ *Variable }
+
+func (e InvalidVariableName) Error() string {
+	return fmt.Sprintf("Invalid Variable Name: %q", e.Variable.Name)
+}
+
+type DuplicateVariableName struct{ *Variable }
+
+func (e DuplicateVariableName) Error() string {
+	return fmt.Sprintf("Duplicate Variable Name: %q", e.Variable.Name)
+}
+
+type StayError struct {
+	Variable *Variable
+	Err      error
+}
+
+func (e StayError) Error() string {
+	return fmt.Sprintf("Stay Variable %v: %v", e.Variable, e.Err)
+}
+
+func (e StayError) Unwrap() error { return e.Err }
+
+type StayErrors []StayError
+
+func (e StayErrors) Error() string {
+	var names []string
+	for _, se := range e {
+		names = append(names, se.Variable.String())
+	}
+	return fmt.Sprintf("Failed Stay Variables: %s", strings.Join(names, ", "))
+}
+
+/*
+ParseError is an error in constraint text. It holds the position of the
+error in the text and the offending token.
+
+For a syntax error Err is nil and the error unwraps to SyntaxError. For
+an error that occurred while evaluating the text, e.g. because of an
+unknown name, Err is that error and the error unwraps to it.
+*/
+type ParseError struct {
+	Source string // line of text containing the error
+	Line   int    // line number, starting at 1
+	Column int    // column in characters, starting at 1
+	Offset int    // byte offset in Source
+	Token  string // offending token, empty at the end of the input
+	Msg    string // description of a syntax error
+	Err    error  // error that occurred during evaluation
+}
+
+/*
+newParseError creates a parse error for the given byte offset in the
+text src, which may consist of multiple lines.
+*/
+func newParseError(src string, offset int, token, msg string, err error) ParseError {
+	line := 1 + strings.Count(src[:offset], "\n")
+	start := strings.LastIndexByte(src[:offset], '\n') + 1
+	end := strings.IndexByte(src[start:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += start
+	}
+	return ParseError{
+		Source: src[start:end],
+		Line:   line,
+		Column: 1 + utf8.RuneCountInString(src[start:offset]),
+		Offset: offset - start,
+		Token:  token,
+		Msg:    msg,
+		Err:    err,
+	}
+}
+
+func (e ParseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d:%d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%d:%d: %v: %s", e.Line, e.Column, SyntaxError, e.Msg)
+}
+
+func (e ParseError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return SyntaxError
+}
+
+/*
+Caret returns the error message followed by the line of text containing
+the error with the offending token underlined by carets, e.g.
+
+	1:5: Syntax Error: expected operand, found *
+	x + * 2
+	    ^
+*/
+func (e ParseError) Caret() string {
+	var sb strings.Builder
+	sb.WriteString(e.Error())
+	sb.WriteString("\n")
+	sb.WriteString(e.Source)
+	sb.WriteString("\n")
+	for _, r := range e.Source[:e.Offset] {
+		if r == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+	carets := utf8.RuneCountInString(e.Token)
+	if carets == 0 {
+		carets = 1
+	}
+	sb.WriteString(strings.Repeat("^", carets))
+	return sb.String()
+}
+
+type ParseErrors []ParseError
+
+func (e ParseErrors) Error() string {
+	var lines []string
+	for _, pe := range e {
+		lines = append(lines, pe.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type DuplicateLabel struct {
+	Label string
+	Line  int
+}
+
+func (e DuplicateLabel) Error() string {
+	return fmt.Sprintf("Duplicate Label: %q already used on line %d", e.Label, e.Line)
 }
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+LoadSolver creates a solver from a solver file. A solver file is a
+document of constraints, see ParseConstraints, that may also declare
+variables with their values, stays and edit variables:
+
+	var x = 10
+	var y = 20
+	x + y <= 100 | strong
+	stay x = 10 | weak
+	edit y = 20 | strong
+
+A variable that is not declared is created on first use with a value of
+0. The value of a stay is the value the variable is anchored to and
+defaults to the value of the variable. The value of an edit variable is
+suggested to the solver. Stays default to optional strength and edit
+variables to strong strength, like AddStay and AddEditVariable.
+
+The lines are applied to the solver in order. The variables keep the
+values they were declared with until the solver's variables are updated.
+The returned scope holds all variables by name.
+
+Returns
+
+	ParseErrors
+One or more lines failed to parse or could not be applied to the
+solver. The errors cover all the lines that failed.
+*/
+func LoadSolver(r io.Reader) (*Solver, *Scope, error) {
+	s := NewSolver()
+	scope := NewScope(WithAutoCreate())
+	err := parseLines(r, func(p *parser, label string, line int) error {
+		d, err := p.parseDeclaration()
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			a, err := p.parseStatement()
+			if err != nil {
+				return err
+			}
+			cs, err := a.NewConstraintsIn(scope)
+			if err != nil {
+				return err
+			}
+			for _, c := range cs {
+				if err := s.AddConstraint(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if label != "" {
+			return p.errorf(d.namePos, "unexpected label on declaration")
+		}
+		v, _ := scope.Lookup(d.name)
+		var options []ConstraintOption
+		if d.strength != nil {
+			options = append(options, WithStrength(*d.strength))
+		}
+		switch d.keyword {
+		case "var":
+			if d.value != nil {
+				v.Value = *d.value
+			}
+		case "stay":
+			if d.value != nil {
+				return s.addStay(v, *d.value, options...)
+			}
+			return s.AddStay(v, options...)
+		case "edit":
+			if err := s.AddEditVariable(v, options...); err != nil {
+				return err
+			}
+			if d.value != nil {
+				return s.SuggestValue(v, *d.value)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, scope, nil
+}
+
+/*
+WriteTo writes the solver as a solver file that can be read back with
+LoadSolver. The file declares the variables with their current values
+sorted by name, followed by the constraints, the stays and the edit
+variables, each in the order in which they were added to the solver.
+
+Returns
+
+	InvalidVariableName
+The name of a variable can't be read back as a name, e.g. because it is
+empty or holds spaces. Nothing is written in that case.
+	DuplicateVariableName
+Two different variables have the same name, they would be read back as
+a single variable. Nothing is written in that case.
+*/
+func (s *Solver) WriteTo(w io.Writer) (int64, error) {
+	vars := s.Variables()
+	names := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		if !isName(v.Name) {
+			return 0, InvalidVariableName{v}
+		}
+		if names[v.Name] {
+			return 0, DuplicateVariableName{v}
+		}
+		names[v.Name] = true
+	}
+	sort.SliceStable(vars, func(i, j int) bool {
+		return vars[i].Name < vars[j].Name
+	})
+	var sections []string
+	var b strings.Builder
+	section := func() {
+		if b.Len() > 0 {
+			sections = append(sections, b.String())
+			b.Reset()
+		}
+	}
+	for _, v := range vars {
+		b.WriteString("var " + v.Name + " = " + formatNumber(v.Value) + "\n")
+	}
+	section()
+	for _, c := range s.Constraints() {
+		b.WriteString(FormatConstraint(c) + "\n")
+	}
+	section()
+	for _, stay := range s.Stays() {
+		b.WriteString("stay " + stay.Variable.Name + " = " + formatNumber(stay.Value) + formatAnnotation(stay.Strength) + "\n")
+	}
+	section()
+	for _, edit := range s.EditVariables() {
+		b.WriteString("edit " + edit.Variable.Name + " = " + formatNumber(edit.Value) + formatAnnotation(edit.Strength) + "\n")
+	}
+	section()
+	n, err := io.WriteString(w, strings.Join(sections, "\n"))
+	return int64(n), err
+}
+
+/*
+isName tests whether the name is a name in canonical form in the
+constraint language, e.g. `col[3].left`.
+*/
+func isName(name string) bool {
+	p := newParser(name)
+	if p.tok != tokIDENT {
+		return false
+	}
+	x, err := p.parseName()
+	return err == nil && p.tok == tokEOF && x.(*ident).name == name
+}
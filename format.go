@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+type formatter struct {
+	left      *Variable
+	termsLeft bool
+	required  bool
+}
+
+type FormatOption func(*formatter)
+
+/*
+WithLeft is a format option to put the given variable on the left of the
+comparison. It has no effect when the variable doesn't appear in the
+constraint.
+*/
+func WithLeft(variable *Variable) FormatOption {
+	return func(f *formatter) {
+		f.left = variable
+	}
+}
+
+// WithTermsLeft is a format option to keep all terms on the left and only move the constant to the right.
+func WithTermsLeft() FormatOption {
+	return func(f *formatter) {
+		f.termsLeft = true
+	}
+}
+
+// WithRequired is a format option to annotate required constraints with `| required`.
+func WithRequired() FormatOption {
+	return func(f *formatter) {
+		f.required = true
+	}
+}
+
+/*
+FormatConstraint formats the constraint as text that ParseConstraint can
+read back, e.g. `xm == 0.125*xr + 0.125*xl + 7 | strong(123)`.
+
+By default a variable with a coefficient of 1 or -1 goes on the left and
+the remaining terms and the constant go on the right. Of those variables
+the one that leaves the fewest negated terms on the right is chosen, then
+the one that comes first by name. When no such variable exists, all terms
+stay on the left and only the constant moves to the right, e.g.
+`2*x + 3*y <= 10`. The operator is reversed where needed so the variable
+on the left has a positive coefficient. Formatting the parsed text again
+results in the same text.
+
+The strength annotation is left out for required constraints, as that is
+the strength the parser defaults to.
+
+Variable names are written as is, so the text can only be read back when
+all names are valid names in the constraint language.
+*/
+func FormatConstraint(c *Constraint, options ...FormatOption) string {
+	var f formatter
+	for _, option := range options {
+		option(&f)
+	}
+
+	var terms []Term
+	for _, t := range c.Expression.Terms {
+		if !NearZero(t.Coefficient) {
+			terms = append(terms, t)
+		}
+	}
+	pivot := -1
+	if !f.termsLeft {
+		for i, t := range terms {
+			if t.Variable == f.left {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			// Don't depend on the position of a variable in the
+			// expression, as that depends on how the constraint was
+			// built. Prefer the right side with the fewest negated
+			// terms, then the variable first by name.
+			negated := func(pivot int) int {
+				n := 0
+				for i, t := range terms {
+					if i != pivot && t.Coefficient*terms[pivot].Coefficient > 0 {
+						n++
+					}
+				}
+				return n
+			}
+			best := 0
+			for i, t := range terms {
+				if t.Coefficient != 1 && t.Coefficient != -1 {
+					continue
+				}
+				n := negated(i)
+				if pivot < 0 || n < best || (n == best && t.Variable.Name < terms[pivot].Variable.Name) {
+					pivot, best = i, n
+				}
+			}
+		}
+	}
+
+	op := c.Operator
+	lhs, rhs, constant := terms, []Term(nil), -c.Expression.Constant
+	if pivot >= 0 {
+		// k*v + rest + c op 0 becomes k*v op -rest - c for k > 0 and
+		// -k*v op' rest + c for k < 0 with the operator reversed.
+		k, sign := terms[pivot].Coefficient, -1.0
+		if k < 0 {
+			k, sign = -k, 1.0
+			switch op {
+			case LE:
+				op = GE
+			case GE:
+				op = LE
+			}
+		}
+		lhs = []Term{{terms[pivot].Variable, k}}
+		for i, t := range terms {
+			if i != pivot {
+				rhs = append(rhs, Term{t.Variable, sign * t.Coefficient})
+			}
+		}
+		constant = sign * c.Expression.Constant
+	}
+
+	var b strings.Builder
+	b.WriteString(formatSum(lhs, 0))
+	b.WriteString(" " + op.String() + " ")
+	b.WriteString(formatSum(rhs, constant))
+	if c.Strength != REQUIRED || f.required {
+		b.WriteString(formatAnnotation(c.Strength))
+	}
+	return b.String()
+}
+
+/*
+formatSum formats the terms followed by the constant, e.g. `-x + 2*y - 3`.
+A zero constant is left out unless there are no terms.
+*/
+func formatSum(terms []Term, constant float64) string {
+	var b strings.Builder
+	item := func(negative bool, text string) {
+		switch {
+		case b.Len() == 0 && negative:
+			b.WriteString("-")
+		case b.Len() > 0 && negative:
+			b.WriteString(" - ")
+		case b.Len() > 0:
+			b.WriteString(" + ")
+		}
+		b.WriteString(text)
+	}
+	for _, t := range terms {
+		text := t.Variable.Name
+		if coefficient := math.Abs(t.Coefficient); coefficient != 1 {
+			text = formatNumber(coefficient) + "*" + text
+		}
+		item(t.Coefficient < 0, text)
+	}
+	if len(terms) == 0 || !NearZero(constant) {
+		item(constant < 0, formatNumber(math.Abs(constant)))
+	}
+	return b.String()
+}
+
+func formatNumber(value float64) string {
+	if value == 0 {
+		// Don't write negative zero as -0
+		value = 0
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatAnnotation(strength Strength) string {
+	return " | " + strings.ToLower(strength.String())
+}
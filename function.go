@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import "strings"
+
+/*
+auxiliary is a variable introduced to express a non-linear function like
+min, max or abs with linear constraints.
+
+The variable of a max is bounded from below by all of its arguments, the
+variable of a min is bounded from above by all of its arguments. These
+bounds are required. The pulls move the variable towards its arguments
+so it settles on the value of the function. The pulls conflict with each
+other when the arguments differ, so they can't be required. A constraint
+that uses the variable is only enforced as strongly as the pulls are.
+*/
+type auxiliary struct {
+	variable *Variable
+	lower    bool
+	pos      int
+	bounds   []*Constraint
+	pulls    []*Constraint
+}
+
+func newAuxiliary(name string, lower bool, args []Expression) *auxiliary {
+	a := &auxiliary{variable: NewVariable(name), lower: lower}
+	for _, arg := range args {
+		if lower {
+			a.bounds = append(a.bounds, a.variable.GreaterThanOrEqualsExpression(arg))
+			a.pulls = append(a.pulls, a.variable.LessThanOrEqualsExpression(arg))
+		} else {
+			a.bounds = append(a.bounds, a.variable.LessThanOrEqualsExpression(arg))
+			a.pulls = append(a.pulls, a.variable.GreaterThanOrEqualsExpression(arg))
+		}
+	}
+	a.pull(WEAK)
+	return a
+}
+
+// pull sets the strength of the pulls.
+func (a *auxiliary) pull(strength Strength) {
+	for _, c := range a.pulls {
+		c.Strength = strength
+	}
+}
+
+func (a *auxiliary) constraints() []*Constraint {
+	return append(append([]*Constraint(nil), a.bounds...), a.pulls...)
+}
+
+// uses tests whether the variable is used in the constraint.
+func (a *auxiliary) uses(c *Constraint) bool {
+	return !NearZero(a.coefficient(c))
+}
+
+func (a *auxiliary) coefficient(c *Constraint) float64 {
+	coeff := 0.0
+	for _, t := range c.Expression.Terms {
+		if t.Variable == a.variable {
+			coeff += t.Coefficient
+		}
+	}
+	return coeff
+}
+
+/*
+needsPulls tests whether the variable is used in the constraint in a way
+that its bounds alone do not capture.
+
+A use is exact when raising the variable of a max, or lowering the
+variable of a min, only ever makes the constraint harder to satisfy. E.g.
+in `w >= max(a, b)` the bounds `m >= a` and `m >= b` are all that is
+needed, but `w <= max(a, b)` would be satisfied by any large enough m.
+*/
+func (a *auxiliary) needsPulls(c *Constraint) bool {
+	coeff := a.coefficient(c)
+	if NearZero(coeff) {
+		return false
+	}
+	if c.Operator == EQ {
+		return true
+	}
+	harder := (c.Operator == LE) == (coeff > 0)
+	return harder != a.lower
+}
+
+func functionName(name string, args []Expression) string {
+	var texts []string
+	for _, arg := range args {
+		texts = append(texts, arg.String())
+	}
+	return name + "(" + strings.Join(texts, ", ") + ")"
+}
+
+/*
+Max returns a variable that holds the maximum of the given expressions,
+together with the constraints that define it.
+
+The constraints must be added to the solver along with any constraint
+that uses the variable. The variable is bounded from below by all the
+expressions using required constraints, and pulled down towards them so
+it settles on the maximum. The pulls are as strong as a constraint that
+is not required can be, so only a required constraint can move the
+variable away from the maximum.
+*/
+func Max(args ...Expression) (*Variable, []*Constraint) {
+	a := newAuxiliary(functionName("max", args), true, args)
+	a.pull(Strong(1000))
+	return a.variable, a.constraints()
+}
+
+/*
+Min returns a variable that holds the minimum of the given expressions,
+together with the constraints that define it.
+
+The variable is bounded from above by all the expressions using required
+constraints, and pulled up towards them as strongly as Max pulls so it
+settles on the minimum.
+*/
+func Min(args ...Expression) (*Variable, []*Constraint) {
+	a := newAuxiliary(functionName("min", args), false, args)
+	a.pull(Strong(1000))
+	return a.variable, a.constraints()
+}
+
+/*
+Abs returns a variable that holds the absolute value of the expression,
+together with the constraints that define it, i.e. `max(x, -x)`.
+*/
+func Abs(arg Expression) (*Variable, []*Constraint) {
+	a := newAuxiliary(functionName("abs", []Expression{arg}), true, []Expression{arg, arg.Negate()})
+	a.pull(Strong(1000))
+	return a.variable, a.constraints()
+}
@@ -17,3 +17,7 @@ require (
 	golang.org/x/sys v0.0.0-20220825204002-c680a09ffe64 // indirect
 	golang.org/x/text v0.7.0 // indirect
 )
+
+// kiwilayout uses APIs of kiwi that are newer than the required version,
+// so it builds against the kiwi of this repository.
+replace github.com/reactivego/kiwi => ../
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package kiwilayout lays out Gio widgets at positions solved for by kiwi.
+
+A Container is a layout.Widget whose children are bound to kiwi boxes.
+The constraints Gio passes to the container become edit variables of the
+solver, so every frame the boxes are solved for the space available and
+the children are laid out at their computed position and size:
+
+	c := kiwilayout.NewContainer(solver)
+	c.Add(title.Box, material.H6(th, "Title").Layout)
+	c.Add(body.Box, list.Layout)
+	...
+	c.Layout(gtx)
+
+The boxes of the children are typically constrained relative to the box
+of the container, e.g. with the builders of the kiwi layout package.
+
+The package uses Box, SetConstant, SuggestValues and the layout package
+of kiwi, which are not part of a tagged release of kiwi yet. Until they
+are, the package only builds inside the kiwi repository, where the gio
+module replaces kiwi by the enclosing module. Importing it from another
+module resolves kiwi to the required release, which lacks these APIs.
+*/
+package kiwilayout
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+
+	"github.com/reactivego/kiwi"
+)
+
+// Child is a widget laid out in the frame of a box.
+type Child struct {
+	Box    *kiwi.Box
+	Widget layout.Widget
+}
+
+/*
+Container lays out its children at the frames of their boxes. The box of
+the container is at the origin and is as large as the maximum of the
+constraints of Gio allows, unless its required constraints say otherwise.
+The size of the container stays within the minimum and maximum of the
+constraints of Gio.
+*/
+type Container struct {
+	Box *kiwi.Box
+
+	solver      *kiwi.Solver
+	children    []Child
+	constraints layout.Constraints
+	minWidth    *kiwi.Constraint
+	maxWidth    *kiwi.Constraint
+	minHeight   *kiwi.Constraint
+	maxHeight   *kiwi.Constraint
+	err         error
+}
+
+// unbounded is the maximum size of a container before its first frame.
+const unbounded = 1e6
+
+// NewContainer returns a container with a box named "container" laid out by the solver.
+func NewContainer(s *kiwi.Solver) *Container {
+	box := kiwi.NewBox("container")
+	c := &Container{
+		Box:         box,
+		solver:      s,
+		constraints: layout.Constraints{Max: image.Pt(unbounded, unbounded)},
+		minWidth:    box.Width.GreaterThanOrEqualsConstant(0),
+		maxWidth:    box.Width.LessThanOrEqualsConstant(unbounded),
+		minHeight:   box.Height.GreaterThanOrEqualsConstant(0),
+		maxHeight:   box.Height.LessThanOrEqualsConstant(unbounded),
+	}
+	c.err = s.AddConstraints([]*kiwi.Constraint{
+		box.Left.EqualsConstant(0),
+		box.Top.EqualsConstant(0),
+		c.minWidth, c.maxWidth, c.minHeight, c.maxHeight,
+	})
+	if c.err == nil {
+		c.err = s.AddEditVariable(box.Width)
+	}
+	if c.err == nil {
+		c.err = s.AddEditVariable(box.Height)
+	}
+	return c
+}
+
+// Add adds a child that lays out the widget in the frame of the box.
+func (c *Container) Add(box *kiwi.Box, widget layout.Widget) {
+	c.children = append(c.children, Child{box, widget})
+}
+
+/*
+Remove removes the child with the given box and reports whether it was
+present. The constraints on the box are left in the solver.
+*/
+func (c *Container) Remove(box *kiwi.Box) bool {
+	for i, child := range c.children {
+		if child.Box == box {
+			c.children = append(c.children[:i], c.children[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Children returns the children of the container in the order they are laid out.
+func (c *Container) Children() []Child {
+	return append([]Child(nil), c.children...)
+}
+
+/*
+Err returns the error of the last frame that could not be solved for the
+constraints of Gio, or nil. The container keeps the bounds of the last
+frame that could be solved in that case, so that frame is laid out
+again, and the next frame tries the constraints of Gio again.
+*/
+func (c *Container) Err() error {
+	return c.err
+}
+
+/*
+Layout solves the boxes for the constraints of the context and lays out
+the children at their frames. The children get exact constraints of the
+size of their box.
+*/
+func (c *Container) Layout(gtx layout.Context) layout.Dimensions {
+	if gtx.Constraints != c.constraints || c.err != nil {
+		c.err = c.constrain(gtx.Constraints)
+		if c.err == nil {
+			c.constraints = gtx.Constraints
+		}
+	}
+	c.solver.UpdateVariables()
+	for _, child := range c.children {
+		offset := image.Pt(round(child.Box.Left.Value-c.Box.Left.Value), round(child.Box.Top.Value-c.Box.Top.Value))
+		size := image.Pt(round(child.Box.Width.Value), round(child.Box.Height.Value))
+		trans := op.Offset(offset).Push(gtx.Ops)
+		cgtx := gtx
+		cgtx.Constraints = layout.Exact(size)
+		child.Widget(cgtx)
+		trans.Pop()
+	}
+	size := image.Pt(round(c.Box.Width.Value), round(c.Box.Height.Value))
+	return layout.Dimensions{Size: gtx.Constraints.Constrain(size)}
+}
+
+/*
+constrain sets the bounds on the size of the container to the constraints
+and suggests the maximum size. When the bounds can't be set, the bounds
+of the last frame are restored.
+*/
+func (c *Container) constrain(gc layout.Constraints) error {
+	if err := c.bound(gc); err != nil {
+		c.bound(c.constraints)
+		return err
+	}
+	return c.solver.SuggestValues(map[*kiwi.Variable]float64{
+		c.Box.Width:  float64(gc.Max.X),
+		c.Box.Height: float64(gc.Max.Y),
+	})
+}
+
+/*
+bound sets the bounds on the size of the container to the constraints.
+The minimums are relaxed first, so the bounds never exclude each other
+while they change.
+*/
+func (c *Container) bound(gc layout.Constraints) error {
+	for _, bound := range []struct {
+		*kiwi.Constraint
+		value int
+	}{
+		{c.minWidth, 0},
+		{c.minHeight, 0},
+		{c.maxWidth, gc.Max.X},
+		{c.maxHeight, gc.Max.Y},
+		{c.minWidth, gc.Min.X},
+		{c.minHeight, gc.Min.Y},
+	} {
+		if err := c.solver.SetConstant(bound.Constraint, -float64(bound.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func round(v float64) int {
+	return int(math.Round(v))
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwilayout
+
+import (
+	"image"
+	"testing"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+
+	"github.com/reactivego/kiwi"
+	klayout "github.com/reactivego/kiwi/layout"
+)
+
+// recorder is a widget that records the constraints it was laid out with.
+type recorder struct {
+	constraints []layout.Constraints
+}
+
+func (r *recorder) Layout(gtx layout.Context) layout.Dimensions {
+	r.constraints = append(r.constraints, gtx.Constraints)
+	return layout.Dimensions{Size: gtx.Constraints.Min}
+}
+
+func TestContainer(t *testing.T) {
+	s := kiwi.NewSolver()
+	c := NewContainer(s)
+	assert.Equal(t, nil, c.Err(), "c.Err()")
+	a, b := kiwi.NewBox("a"), kiwi.NewBox("b")
+	row := klayout.NewFlex(c.Box, klayout.Row, klayout.WithGap(10))
+	row.Append(&klayout.Item{Box: a, Preferred: 100}, &klayout.Item{Box: b, Grow: 1})
+	assert.Equal(t, nil, row.Update(s), "row.Update(s)")
+	ra, rb := &recorder{}, &recorder{}
+	c.Add(a, ra.Layout)
+	c.Add(b, rb.Layout)
+
+	// Headless, the ops are recorded but never rendered
+	gtx := layout.Context{Ops: new(op.Ops), Constraints: layout.Exact(image.Pt(400, 300))}
+	dims := c.Layout(gtx)
+	assert.Equal(t, nil, c.Err(), "c.Err()")
+	assert.Equal(t, image.Pt(400, 300), dims.Size, "dims.Size")
+	assert.Equal(t, layout.Exact(image.Pt(100, 300)), ra.constraints[0], "ra.constraints[0]")
+	assert.Equal(t, layout.Exact(image.Pt(290, 300)), rb.constraints[0], "rb.constraints[0]")
+	assert.Equal(t, 110.0, b.Left.Value, "b.Left")
+
+	// The container fills the maximum of loose constraints
+	gtx.Ops.Reset()
+	gtx.Constraints = layout.Constraints{Min: image.Pt(50, 50), Max: image.Pt(200, 100)}
+	dims = c.Layout(gtx)
+	assert.Equal(t, image.Pt(200, 100), dims.Size, "dims.Size")
+	assert.Equal(t, layout.Exact(image.Pt(90, 100)), rb.constraints[1], "rb.constraints[1]")
+
+	// Required constraints on the container win from the edit variables
+	assert.Equal(t, nil, s.AddConstraint(c.Box.Width.LessThanOrEqualsConstant(150)), "s.AddConstraint")
+	gtx.Ops.Reset()
+	dims = c.Layout(gtx)
+	assert.Equal(t, image.Pt(150, 100), dims.Size, "dims.Size")
+	assert.Equal(t, layout.Exact(image.Pt(40, 100)), rb.constraints[2], "rb.constraints[2]")
+
+	assert.Equal(t, true, c.Remove(a), "c.Remove(a)")
+	gtx.Ops.Reset()
+	c.Layout(gtx)
+	assert.Equal(t, 3, len(ra.constraints), "len(ra.constraints)")
+	assert.Equal(t, 4, len(rb.constraints), "len(rb.constraints)")
+}
+
+func TestContainerRecovers(t *testing.T) {
+	s := kiwi.NewSolver()
+	c := NewContainer(s)
+	a := kiwi.NewBox("a")
+	assert.Equal(t, nil, s.AddConstraints(a.Inside(c.Box, 10)), "s.AddConstraints")
+	ra := &recorder{}
+	c.Add(a, ra.Layout)
+
+	// A failed frame is tried again for the same constraints of Gio
+	wide := c.Box.Width.GreaterThanOrEqualsConstant(500)
+	assert.Equal(t, nil, s.AddConstraint(wide), "s.AddConstraint")
+	gtx := layout.Context{Ops: new(op.Ops), Constraints: layout.Exact(image.Pt(400, 300))}
+	c.Layout(gtx)
+	assert.Equal(t, kiwi.UnsatisfiableConstraint{Constraint: c.maxWidth}, c.Err(), "c.Err()")
+	assert.Equal(t, nil, s.RemoveConstraint(wide), "s.RemoveConstraint")
+	gtx.Ops.Reset()
+	dims := c.Layout(gtx)
+	assert.Equal(t, nil, c.Err(), "c.Err()")
+	assert.Equal(t, image.Pt(400, 300), dims.Size, "dims.Size")
+	assert.Equal(t, layout.Exact(image.Pt(380, 280)), ra.constraints[1], "ra.constraints[1]")
+
+	// The last frame that could be solved is laid out until the next one can
+	narrow := c.Box.Width.LessThanOrEqualsConstant(450)
+	assert.Equal(t, nil, s.AddConstraint(narrow), "s.AddConstraint")
+	gtx.Ops.Reset()
+	gtx.Constraints = layout.Exact(image.Pt(600, 300))
+	c.Layout(gtx)
+	assert.Equal(t, kiwi.UnsatisfiableConstraint{Constraint: c.minWidth}, c.Err(), "c.Err()")
+	assert.Equal(t, layout.Exact(image.Pt(380, 280)), ra.constraints[2], "ra.constraints[2]")
+	assert.Equal(t, nil, s.RemoveConstraint(narrow), "s.RemoveConstraint")
+	gtx.Ops.Reset()
+	dims = c.Layout(gtx)
+	assert.Equal(t, nil, c.Err(), "c.Err()")
+	assert.Equal(t, image.Pt(600, 300), dims.Size, "dims.Size")
+	assert.Equal(t, layout.Exact(image.Pt(580, 280)), ra.constraints[3], "ra.constraints[3]")
+}
+
+var assert = struct {
+	Equal func(t *testing.T, exp, got interface{}, msg string, info ...interface{})
+}{
+	Equal: func(t *testing.T, exp, got interface{}, msg string, info ...interface{}) {
+		t.Helper()
+		if exp != got {
+			t.Errorf(msg+" expected %#v got %#v", append(append(info, exp), got)...)
+		}
+	},
+}
@@ -0,0 +1,366 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+The JSON form of a constraint refers to its variables by name, e.g.
+
+	{
+	  "expression": {
+	    "terms": [{"variable": "x", "coefficient": 1}],
+	    "constant": -10
+	  },
+	  "operator": "==",
+	  "strength": "strong(123)"
+	}
+
+Decoding creates a new variable for every name, so terms with the same
+name within a single expression or constraint share a variable. Use a
+SolverSpec to share variables across constraints.
+*/
+
+type variableJSON struct {
+	ID    string  `json:"id,omitempty"`
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+type termJSON struct {
+	Variable    string  `json:"variable"`
+	Coefficient float64 `json:"coefficient"`
+}
+
+type expressionJSON struct {
+	Terms    []termJSON `json:"terms"`
+	Constant float64    `json:"constant"`
+}
+
+type constraintJSON struct {
+	Expression expressionJSON `json:"expression"`
+	Operator   Operator       `json:"operator"`
+	Strength   *Strength      `json:"strength,omitempty"`
+}
+
+type anchorJSON struct {
+	Variable string    `json:"variable"`
+	Value    float64   `json:"value"`
+	Strength *Strength `json:"strength,omitempty"`
+}
+
+type solverJSON struct {
+	Variables   []variableJSON   `json:"variables"`
+	Constraints []constraintJSON `json:"constraints"`
+	Stays       []anchorJSON     `json:"stays"`
+	Edits       []anchorJSON     `json:"edits"`
+}
+
+func byName(v *Variable) string {
+	return v.Name
+}
+
+/*
+resolver returns a function that resolves names to variables, creating
+a variable for a name the first time it is resolved.
+*/
+func resolver() func(string) *Variable {
+	vars := make(map[string]*Variable)
+	return func(name string) *Variable {
+		v, present := vars[name]
+		if !present {
+			v = NewVariable(name)
+			vars[name] = v
+		}
+		return v
+	}
+}
+
+func (e Expression) toJSON(ref func(*Variable) string) expressionJSON {
+	j := expressionJSON{Terms: make([]termJSON, 0, len(e.Terms)), Constant: e.Constant}
+	for _, t := range e.Terms {
+		j.Terms = append(j.Terms, termJSON{ref(t.Variable), t.Coefficient})
+	}
+	return j
+}
+
+func (j expressionJSON) expression(resolve func(string) *Variable) Expression {
+	e := Expression{Terms: make([]Term, 0, len(j.Terms)), Constant: j.Constant}
+	for _, t := range j.Terms {
+		e.Terms = append(e.Terms, Term{resolve(t.Variable), t.Coefficient})
+	}
+	return e
+}
+
+func (c *Constraint) toJSON(ref func(*Variable) string) constraintJSON {
+	strength := c.Strength
+	return constraintJSON{c.Expression.toJSON(ref), c.Operator, &strength}
+}
+
+/*
+constraint creates the constraint, which is required when the JSON form
+doesn't give a strength.
+*/
+func (j constraintJSON) constraint(resolve func(string) *Variable) *Constraint {
+	return NewConstraint(j.Expression.expression(resolve), j.Operator, WithStrength(strengthOr(j.Strength, REQUIRED)))
+}
+
+func strengthOr(strength *Strength, fallback Strength) Strength {
+	if strength == nil {
+		return fallback
+	}
+	return *strength
+}
+
+func newAnchorJSON(ref string, value float64, strength Strength) anchorJSON {
+	return anchorJSON{ref, value, &strength}
+}
+
+func (v *Variable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(variableJSON{Name: v.Name, Value: v.Value})
+}
+
+func (v *Variable) UnmarshalJSON(data []byte) error {
+	var j variableJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	v.Name, v.Value = j.Name, j.Value
+	return nil
+}
+
+func (t Term) MarshalJSON() ([]byte, error) {
+	return json.Marshal(termJSON{t.Variable.Name, t.Coefficient})
+}
+
+func (t *Term) UnmarshalJSON(data []byte) error {
+	var j termJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*t = Term{NewVariable(j.Variable), j.Coefficient}
+	return nil
+}
+
+func (e Expression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.toJSON(byName))
+}
+
+func (e *Expression) UnmarshalJSON(data []byte) error {
+	var j expressionJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*e = j.expression(resolver())
+	return nil
+}
+
+func (c *Constraint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.toJSON(byName))
+}
+
+func (c *Constraint) UnmarshalJSON(data []byte) error {
+	var j constraintJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*c = *j.constraint(resolver())
+	return nil
+}
+
+/*
+MarshalJSON encodes the strength as the text of a strength annotation,
+e.g. "required", "strong(123)" or "12345.5".
+*/
+func (s Strength) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(s.String()))
+}
+
+/*
+UnmarshalJSON decodes a strength from the text of a strength annotation
+in any case, or from a number.
+*/
+func (s *Strength) UnmarshalJSON(data []byte) error {
+	var value float64
+	if err := json.Unmarshal(data, &value); err == nil {
+		*s = Strength(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	p := newParser(text)
+	strength, err := p.parseStrength()
+	if err != nil {
+		return err
+	}
+	if p.tok != tokEOF {
+		return p.unexpected("end of strength")
+	}
+	*s = strength
+	return nil
+}
+
+func (o Operator) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+func (o *Operator) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	for _, op := range []Operator{LE, GE, EQ} {
+		if text == op.String() {
+			*o = op
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid operator %q", text)
+}
+
+/*
+SolverSpec describes the complete configuration of a solver: its
+variables with their values, its constraints, its stays and its edit
+variables.
+
+In JSON the constraints, stays and edit variables refer to the variables
+by name. When a solver holds different variables with the same name, the
+variables get an id like `x#2` and are referred to by their id instead.
+A name that doesn't refer to a variable in the list of variables results
+in an UnknownVariableName error. A missing strength defaults to required
+for constraints, to optional for stays and to strong for edit variables,
+as in the solver.
+*/
+type SolverSpec struct {
+	Variables   []*Variable
+	Constraints []*Constraint
+	Stays       []Stay
+	Edits       []EditVariable
+}
+
+// Spec returns the specification of the solver's current configuration.
+func (s *Solver) Spec() SolverSpec {
+	return SolverSpec{
+		Variables:   s.Variables(),
+		Constraints: s.Constraints(),
+		Stays:       s.Stays(),
+		Edits:       s.EditVariables(),
+	}
+}
+
+/*
+NewSolver creates a solver from the specification. The constraints are
+added first, then the stays anchored to their values and finally the
+edit variables with their suggested values.
+*/
+func (spec SolverSpec) NewSolver() (*Solver, error) {
+	s := NewSolver()
+	for _, c := range spec.Constraints {
+		if err := s.AddConstraint(c); err != nil {
+			return nil, err
+		}
+	}
+	for _, stay := range spec.Stays {
+		if err := s.addStay(stay.Variable, stay.Value, WithStrength(stay.Strength)); err != nil {
+			return nil, err
+		}
+	}
+	for _, edit := range spec.Edits {
+		if err := s.AddEditVariable(edit.Variable, WithStrength(edit.Strength)); err != nil {
+			return nil, err
+		}
+		if err := s.SuggestValue(edit.Variable, edit.Value); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (spec SolverSpec) MarshalJSON() ([]byte, error) {
+	count := make(map[string]int)
+	for _, v := range spec.Variables {
+		count[v.Name]++
+	}
+	ids := make(map[*Variable]string)
+	seen := make(map[string]int)
+	j := solverJSON{
+		Variables:   []variableJSON{},
+		Constraints: []constraintJSON{},
+		Stays:       []anchorJSON{},
+		Edits:       []anchorJSON{},
+	}
+	for _, v := range spec.Variables {
+		id := ""
+		if count[v.Name] > 1 {
+			seen[v.Name]++
+			id = fmt.Sprintf("%s#%d", v.Name, seen[v.Name])
+			ids[v] = id
+		}
+		j.Variables = append(j.Variables, variableJSON{id, v.Name, v.Value})
+	}
+	ref := func(v *Variable) string {
+		if id, present := ids[v]; present {
+			return id
+		}
+		return v.Name
+	}
+	for _, c := range spec.Constraints {
+		j.Constraints = append(j.Constraints, c.toJSON(ref))
+	}
+	for _, stay := range spec.Stays {
+		j.Stays = append(j.Stays, newAnchorJSON(ref(stay.Variable), stay.Value, stay.Strength))
+	}
+	for _, edit := range spec.Edits {
+		j.Edits = append(j.Edits, newAnchorJSON(ref(edit.Variable), edit.Value, edit.Strength))
+	}
+	return json.Marshal(j)
+}
+
+func (spec *SolverSpec) UnmarshalJSON(data []byte) error {
+	var j solverJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	var decoded SolverSpec
+	vars := make(map[string]*Variable)
+	for _, vj := range j.Variables {
+		v := Var(vj.Name, vj.Value)
+		ref := vj.ID
+		if ref == "" {
+			ref = vj.Name
+		}
+		if _, present := vars[ref]; !present {
+			vars[ref] = v
+		}
+		decoded.Variables = append(decoded.Variables, v)
+	}
+	var unknown []string
+	resolve := func(ref string) *Variable {
+		v, present := vars[ref]
+		if !present {
+			unknown = append(unknown, ref)
+			return NewVariable(ref)
+		}
+		return v
+	}
+	for _, cj := range j.Constraints {
+		decoded.Constraints = append(decoded.Constraints, cj.constraint(resolve))
+	}
+	for _, aj := range j.Stays {
+		decoded.Stays = append(decoded.Stays, Stay{resolve(aj.Variable), strengthOr(aj.Strength, OPTIONAL), aj.Value})
+	}
+	for _, aj := range j.Edits {
+		decoded.Edits = append(decoded.Edits, EditVariable{resolve(aj.Variable), strengthOr(aj.Strength, STRONG), aj.Value})
+	}
+	if unknown != nil {
+		return UnknownVariableName{unknown[0]}
+	}
+	*spec = decoded
+	return nil
+}
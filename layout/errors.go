@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package layout
+
+import (
+	"fmt"
+
+	"github.com/reactivego/kiwi"
+)
+
+const NotConverged = kiwi.Error("Not Converged")
+
+type CellOutsideGrid struct{ *Cell }
+
+func (e CellOutsideGrid) Error() string {
+	return fmt.Sprintf("Cell Outside Grid: column %d span %d, row %d span %d", e.Column, span(e.ColumnSpan), e.Row, span(e.RowSpan))
+}
+
+type CyclicNode struct{ *Node }
+
+func (e CyclicNode) Error() string {
+	return fmt.Sprintf("Cyclic Node: %q", e.Name)
+}
+
+type RootNode struct{ *Node }
+
+func (e RootNode) Error() string {
+	return fmt.Sprintf("Root Node: %q", e.Name)
+}
+
+type UnknownChild struct{ *Node }
+
+func (e UnknownChild) Error() string {
+	return fmt.Sprintf("Unknown Child: %q", e.Name)
+}
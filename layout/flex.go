@@ -0,0 +1,283 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package layout
+
+import (
+	"github.com/reactivego/kiwi"
+)
+
+// Direction is the main axis of a Flex.
+type Direction int
+
+const (
+	Row Direction = iota
+	Column
+)
+
+// Align is the position of the children of a Flex along the cross axis.
+type Align int
+
+const (
+	// AlignStretch stretches the children to fill the container.
+	AlignStretch Align = iota
+	AlignStart
+	AlignCenter
+	AlignEnd
+)
+
+/*
+Item is a child of a Flex. The sizes are along the main axis of the Flex,
+a zero Max means the size has no maximum. A child with a positive Grow
+factor takes a share of the space left over in the container.
+
+The fields should not be changed while the item is in a Flex, instead
+remove the item and insert it again.
+*/
+type Item struct {
+	Box                 *kiwi.Box
+	Min, Preferred, Max float64
+	Grow                float64
+
+	cns []*kiwi.Constraint
+}
+
+/*
+Flex is a row or column of child boxes inside a container box. The
+constraints of the layout are kept up to date in a solver by Update,
+which only adds and removes the constraints that changed since the
+previous update.
+*/
+type Flex struct {
+	Container *kiwi.Box
+
+	direction Direction
+	gap       float64
+	padding   float64
+	align     Align
+	items     []*Item
+	unit      *kiwi.Variable
+	positive  *kiwi.Constraint
+	links     map[link]*kiwi.Constraint
+	applied   []*kiwi.Constraint
+}
+
+/*
+link is a constraint between neighbouring children, or between the
+container and its first or last child when from or to is nil.
+*/
+type link struct {
+	from, to *kiwi.Box
+	fill     bool
+}
+
+type Option func(*Flex)
+
+// WithGap is an option to set the space between neighbouring children.
+func WithGap(gap float64) Option {
+	return func(f *Flex) {
+		f.gap = gap
+	}
+}
+
+// WithPadding is an option to set the space between the container and the children.
+func WithPadding(padding float64) Option {
+	return func(f *Flex) {
+		f.padding = padding
+	}
+}
+
+// WithAlign is an option to set the alignment of the children along the cross axis.
+func WithAlign(align Align) Option {
+	return func(f *Flex) {
+		f.align = align
+	}
+}
+
+/*
+NewFlex returns an empty row or column inside the container. The variable
+of the grow unit is named after the container, e.g. `window.grow`.
+*/
+func NewFlex(container *kiwi.Box, direction Direction, options ...Option) *Flex {
+	unit := kiwi.NewVariable(prefix(container) + "grow")
+	f := &Flex{
+		Container: container,
+		direction: direction,
+		unit:      unit,
+		positive:  unit.GreaterThanOrEqualsConstant(0),
+		links:     make(map[link]*kiwi.Constraint),
+	}
+	for _, option := range options {
+		option(f)
+	}
+	return f
+}
+
+// Items returns the children of the flex in order.
+func (f *Flex) Items() []*Item {
+	return append([]*Item(nil), f.items...)
+}
+
+// Append adds the items after the last child.
+func (f *Flex) Append(items ...*Item) {
+	f.Insert(len(f.items), items...)
+}
+
+// Insert adds the items before the child at index i.
+func (f *Flex) Insert(i int, items ...*Item) {
+	f.items = append(f.items[:i], append(append([]*Item(nil), items...), f.items[i:]...)...)
+}
+
+// Remove removes the child with the given box and reports whether it was present.
+func (f *Flex) Remove(box *kiwi.Box) bool {
+	for i, item := range f.items {
+		if item.Box == box {
+			f.items = append(f.items[:i], f.items[i+1:]...)
+			item.cns = nil
+			return true
+		}
+	}
+	return false
+}
+
+// main returns the position and size variables along the main axis.
+func (f *Flex) main(b *kiwi.Box) (*kiwi.Variable, *kiwi.Variable) {
+	if f.direction == Column {
+		return b.Top, b.Height
+	}
+	return b.Left, b.Width
+}
+
+// cross returns the position and size variables along the cross axis.
+func (f *Flex) cross(b *kiwi.Box) (*kiwi.Variable, *kiwi.Variable) {
+	if f.direction == Column {
+		return b.Left, b.Width
+	}
+	return b.Top, b.Height
+}
+
+/*
+Constraints returns the constraints of the layout in its current state.
+Constraints that did not change since the previous call are returned
+again, so they can be recognized by a solver.
+*/
+func (f *Flex) Constraints() []*kiwi.Constraint {
+	cns := []*kiwi.Constraint{}
+	growing := false
+	for _, item := range f.items {
+		if item.cns == nil {
+			item.cns = f.itemConstraints(item)
+		}
+		cns = append(cns, item.cns...)
+		growing = growing || item.Grow > 0
+	}
+	if growing {
+		cns = append(cns, f.positive)
+	}
+
+	links := make(map[link]*kiwi.Constraint)
+	add := func(l link) {
+		c, present := f.links[l]
+		if !present {
+			c = f.linkConstraint(l)
+		}
+		links[l] = c
+		cns = append(cns, c)
+	}
+	var previous *kiwi.Box
+	for _, item := range f.items {
+		add(link{from: previous, to: item.Box})
+		previous = item.Box
+	}
+	if previous != nil {
+		add(link{from: previous})
+		if growing {
+			add(link{from: previous, fill: true})
+		}
+	}
+	f.links = links
+	return cns
+}
+
+/*
+itemConstraints returns the constraints on the size of a child along the
+main axis and its position along the cross axis.
+*/
+func (f *Flex) itemConstraints(item *Item) []*kiwi.Constraint {
+	_, size := f.main(item.Box)
+	preferred := size.EqualsConstant(item.Preferred)
+	if item.Grow > 0 {
+		preferred = size.EqualsExpression(f.unit.Multiply(item.Grow).AddConstant(item.Preferred))
+	}
+	preferred.Strength = kiwi.WEAK
+	cns := []*kiwi.Constraint{size.GreaterThanOrEqualsConstant(item.Min), preferred}
+	if item.Max > 0 {
+		cns = append(cns, size.LessThanOrEqualsConstant(item.Max))
+	}
+
+	position, extent := f.cross(item.Box)
+	outerPosition, outerExtent := f.cross(f.Container)
+	switch f.align {
+	case AlignStretch:
+		stretch := extent.EqualsExpression(outerExtent.AddConstant(-2 * f.padding))
+		stretch.Strength = kiwi.STRONG
+		cns = append(cns, position.EqualsExpression(outerPosition.AddConstant(f.padding)), stretch)
+	case AlignStart:
+		cns = append(cns, position.EqualsExpression(outerPosition.AddConstant(f.padding)))
+	case AlignCenter:
+		center := position.AddTerm(extent.Divide(2))
+		cns = append(cns, center.EqualsExpression(outerPosition.AddTerm(outerExtent.Divide(2))))
+	case AlignEnd:
+		end := position.AddVariable(extent)
+		cns = append(cns, end.EqualsExpression(outerPosition.AddVariable(outerExtent).AddConstant(-f.padding)))
+	}
+	return cns
+}
+
+/*
+linkConstraint returns the constraint that places a child after its
+neighbour, or the first child at the start of the container. The last
+child should end before the end of the container, and when children grow
+they fill the container up to its end.
+*/
+func (f *Flex) linkConstraint(l link) *kiwi.Constraint {
+	if l.to != nil {
+		position, _ := f.main(l.to)
+		if l.from == nil {
+			start, _ := f.main(f.Container)
+			return position.EqualsExpression(start.AddConstant(f.padding))
+		}
+		previous, size := f.main(l.from)
+		return position.EqualsExpression(previous.AddVariable(size).AddConstant(f.gap))
+	}
+	last, size := f.main(l.from)
+	start, extent := f.main(f.Container)
+	end := last.AddVariable(size)
+	bound := start.AddVariable(extent).AddConstant(-f.padding)
+	if l.fill {
+		fill := end.EqualsExpression(bound)
+		fill.Strength = kiwi.MEDIUM
+		return fill
+	}
+	fit := end.LessThanOrEqualsExpression(bound)
+	fit.Strength = kiwi.STRONG
+	return fit
+}
+
+/*
+Update brings the constraints of the layout in the solver up to date.
+Constraints of the previous update that are no longer part of the layout
+are removed and new ones are added, constraints that did not change are
+left alone. Update continues with the remaining constraints when one of
+them can't be added.
+
+Returns
+
+	kiwi.UnsatisfiableConstraint
+A required constraint of the layout cannot be satisfied, e.g. when the
+minimum size of a child is larger than its maximum size.
+*/
+func (f *Flex) Update(s *kiwi.Solver) error {
+	var err error
+	f.applied, err = update(s, f.applied, f.Constraints())
+	return err
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package layout
+
+import (
+	"fmt"
+
+	"github.com/reactivego/kiwi"
+)
+
+type trackKind int
+
+const (
+	fixed trackKind = iota
+	fractional
+	minContent
+)
+
+// Track is the size of a column or row of a Grid.
+type Track struct {
+	kind trackKind
+	size float64
+}
+
+// Fixed returns a track with a fixed size.
+func Fixed(size float64) Track {
+	return Track{fixed, size}
+}
+
+/*
+Fr returns a track that takes a fraction of the space left over by the
+other tracks, like the `fr` unit of CSS grid. Tracks of e.g. Fr(1) and
+Fr(2) divide the space left over in 1 and 2 thirds.
+*/
+func Fr(fraction float64) Track {
+	return Track{fractional, fraction}
+}
+
+/*
+MinContent returns a track that is as small as possible while fitting
+the minimum sizes of the cells in the track.
+*/
+func MinContent() Track {
+	return Track{kind: minContent}
+}
+
+/*
+Cell places a box in a Grid. Column and Row are the indices of the first
+track of the cell, ColumnSpan and RowSpan the number of tracks it spans,
+where zero counts as one. The box fills the tracks of the cell, including
+the gaps between them. A track is made larger to fit the minimum size of
+a cell, unless the track has a fixed size or is a fraction.
+*/
+type Cell struct {
+	Box                 *kiwi.Box
+	Column, Row         int
+	ColumnSpan, RowSpan int
+	MinWidth, MinHeight float64
+
+	cns []*kiwi.Constraint
+}
+
+/*
+Grid places boxes in cells between the column and row lines inside a
+container box. Columns and Rows are the variables of the lines, the
+first line is at the start of the container and the line after the last
+track at its end. A track starts at its line and ends at the next line
+minus the gap, so the gap is at the end of every track but the last.
+*/
+type Grid struct {
+	Container     *kiwi.Box
+	Columns, Rows []*kiwi.Variable
+
+	columns, rows axis
+	cells         []*Cell
+	applied       []*kiwi.Constraint
+}
+
+// axis is the columns or the rows of a grid.
+type axis struct {
+	tracks []Track
+	lines  []*kiwi.Variable
+	gap    float64
+	unit   *kiwi.Variable
+	cns    []*kiwi.Constraint
+}
+
+type GridOption func(*Grid)
+
+// WithGaps is an option to set the space between the columns and between the rows.
+func WithGaps(column, row float64) GridOption {
+	return func(g *Grid) {
+		g.columns.gap, g.rows.gap = column, row
+	}
+}
+
+/*
+NewGrid returns an empty grid with the column and row tracks inside the
+container. The lines are named after the container, e.g. `window.column0`.
+*/
+func NewGrid(container *kiwi.Box, columns, rows []Track, options ...GridOption) *Grid {
+	g := &Grid{
+		Container: container,
+		columns:   newAxis(prefix(container)+"column", columns),
+		rows:      newAxis(prefix(container)+"row", rows),
+	}
+	for _, option := range options {
+		option(g)
+	}
+	g.Columns, g.Rows = g.columns.lines, g.rows.lines
+	g.columns.constrain(container.Left, container.Width)
+	g.rows.constrain(container.Top, container.Height)
+	return g
+}
+
+func newAxis(name string, tracks []Track) axis {
+	a := axis{tracks: tracks, unit: kiwi.NewVariable(name + ".fr")}
+	for i := 0; i <= len(tracks); i++ {
+		a.lines = append(a.lines, kiwi.NewVariable(fmt.Sprintf("%s%d", name, i)))
+	}
+	return a
+}
+
+// start returns the start of track i.
+func (a *axis) start(i int) kiwi.Expression {
+	return a.lines[i].AddConstant(0)
+}
+
+// end returns the end of track i, the gap before the next line.
+func (a *axis) end(i int) kiwi.Expression {
+	if i+1 == len(a.tracks) {
+		return a.lines[i+1].AddConstant(0)
+	}
+	return a.lines[i+1].AddConstant(-a.gap)
+}
+
+// size returns the size of the tracks first up to and including last.
+func (a *axis) size(first, last int) kiwi.Expression {
+	return a.end(last).AddTerm(a.lines[first].Negate())
+}
+
+/*
+constrain sets the constraints of the lines and tracks of the axis
+between the start of the container and its end.
+*/
+func (a *axis) constrain(start, extent *kiwi.Variable) {
+	n := len(a.tracks)
+	a.cns = append(a.cns, a.lines[0].EqualsVariable(start))
+	if n == 0 {
+		return
+	}
+	end := start.AddVariable(extent)
+	fit := a.lines[n].LessThanOrEqualsExpression(end)
+	fit.Strength = kiwi.STRONG
+	a.cns = append(a.cns, fit)
+	growing := false
+	for i, track := range a.tracks {
+		a.cns = append(a.cns, a.size(i, i).GreaterThanOrEqualsConstant(0))
+		size := a.size(i, i)
+		switch track.kind {
+		case fixed:
+			a.cns = append(a.cns, size.EqualsConstant(track.size))
+		case fractional:
+			a.cns = append(a.cns, size.EqualsTerm(a.unit.Multiply(track.size)))
+			growing = true
+		case minContent:
+			shrink := size.EqualsConstant(0)
+			shrink.Strength = kiwi.WEAK
+			a.cns = append(a.cns, shrink)
+		}
+	}
+	if growing {
+		fill := a.lines[n].EqualsExpression(end)
+		fill.Strength = kiwi.MEDIUM
+		a.cns = append(a.cns, fill, a.unit.GreaterThanOrEqualsConstant(0))
+	}
+}
+
+/*
+Place adds a cell to the grid.
+
+Returns
+
+	CellOutsideGrid
+The tracks of the cell are not all part of the grid.
+*/
+func (g *Grid) Place(cell *Cell) error {
+	if cell.Column < 0 || cell.Row < 0 || cell.Column+span(cell.ColumnSpan) > len(g.columns.tracks) || cell.Row+span(cell.RowSpan) > len(g.rows.tracks) {
+		return CellOutsideGrid{cell}
+	}
+	g.cells = append(g.cells, cell)
+	return nil
+}
+
+// Remove removes the cell with the given box and reports whether it was present.
+func (g *Grid) Remove(box *kiwi.Box) bool {
+	for i, cell := range g.cells {
+		if cell.Box == box {
+			g.cells = append(g.cells[:i], g.cells[i+1:]...)
+			cell.cns = nil
+			return true
+		}
+	}
+	return false
+}
+
+// Cells returns the cells of the grid in the order they were placed.
+func (g *Grid) Cells() []*Cell {
+	return append([]*Cell(nil), g.cells...)
+}
+
+func span(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+/*
+Constraints returns the constraints of the layout in its current state.
+Constraints that did not change since the previous call are returned
+again, so they can be recognized by a solver.
+*/
+func (g *Grid) Constraints() []*kiwi.Constraint {
+	cns := append(append([]*kiwi.Constraint{}, g.columns.cns...), g.rows.cns...)
+	for _, cell := range g.cells {
+		if cell.cns == nil {
+			cell.cns = g.cellConstraints(cell)
+		}
+		cns = append(cns, cell.cns...)
+	}
+	return cns
+}
+
+/*
+cellConstraints returns the constraints that make the box of the cell
+fill its tracks and the tracks fit the minimum size of the cell.
+*/
+func (g *Grid) cellConstraints(cell *Cell) []*kiwi.Constraint {
+	var cns []*kiwi.Constraint
+	place := func(a *axis, first, n int, position, extent *kiwi.Variable, min float64) {
+		last := first + span(n) - 1
+		cns = append(cns, position.EqualsExpression(a.start(first)), extent.EqualsExpression(a.size(first, last)))
+		if min > 0 {
+			fit := a.size(first, last).GreaterThanOrEqualsConstant(min)
+			fit.Strength = kiwi.STRONG
+			cns = append(cns, fit)
+		}
+	}
+	place(&g.columns, cell.Column, cell.ColumnSpan, cell.Box.Left, cell.Box.Width, cell.MinWidth)
+	place(&g.rows, cell.Row, cell.RowSpan, cell.Box.Top, cell.Box.Height, cell.MinHeight)
+	return cns
+}
+
+/*
+Update brings the constraints of the layout in the solver up to date.
+Constraints of the previous update that are no longer part of the layout
+are removed and new ones are added, constraints that did not change are
+left alone. Update continues with the remaining constraints when one of
+them can't be added.
+
+Returns
+
+	kiwi.UnsatisfiableConstraint
+A required constraint of the layout cannot be satisfied, e.g. when the
+box of a cell is given a size that differs from the size of its tracks.
+*/
+func (g *Grid) Update(s *kiwi.Solver) error {
+	var err error
+	g.applied, err = update(s, g.applied, g.Constraints())
+	return err
+}
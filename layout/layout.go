@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package layout builds kiwi constraints for common layouts of boxes.
+
+A Flex lines up child boxes in a row or a column inside a container box,
+in the spirit of CSS flexbox. Every child is an Item with a minimum,
+preferred and maximum size along the main axis and a grow factor:
+
+	row := layout.NewFlex(window, layout.Row, layout.WithGap(8), layout.WithPadding(10))
+	row.Append(&layout.Item{Box: icon, Preferred: 24})
+	row.Append(&layout.Item{Box: label, Min: 40, Grow: 1})
+	err := row.Update(solver)
+
+The children and the gaps between them are required, as are the minimum
+and maximum sizes. The preferred sizes are weak, so they give way when
+the children don't fit. The space left over is divided over the growing
+children in proportion to their grow factor.
+
+A Grid places child boxes in cells between column and row lines, in the
+spirit of CSS grid. The columns and rows are tracks with a fixed size, a
+fraction of the space left over or the size of their content:
+
+	grid := layout.NewGrid(dashboard,
+		[]layout.Track{layout.Fixed(200), layout.Fr(1), layout.Fr(1)},
+		[]layout.Track{layout.MinContent(), layout.Fr(1)},
+		layout.WithGaps(8, 8))
+	grid.Place(&layout.Cell{Box: header, ColumnSpan: 3})
+	err := grid.Update(solver)
+
+Both layouts keep their constraints in a solver up to date with Update,
+which only adds and removes the constraints that changed since the
+previous update.
+*/
+package layout
+
+import (
+	"strings"
+
+	"github.com/reactivego/kiwi"
+)
+
+/*
+prefix returns the name of the container box followed by a dot, e.g.
+`window.` for a box created by kiwi.NewBox("window"). The variables of a
+layout are named with it, so the variables of layouts in different
+containers can be told apart.
+*/
+func prefix(container *kiwi.Box) string {
+	name := container.Left.Name
+	if strings.HasSuffix(name, ".left") {
+		return strings.TrimSuffix(name, "left")
+	}
+	if name == "" {
+		return ""
+	}
+	return name + "."
+}
+
+/*
+update brings the constraints in the solver up to date. The applied
+constraints that are no longer wanted are removed and the wanted ones
+that are not in the solver are added. Constraints that can't be added
+are skipped and the first error is returned. Returns the constraints that
+are now applied.
+*/
+func update(s *kiwi.Solver, applied, cns []*kiwi.Constraint) ([]*kiwi.Constraint, error) {
+	wanted := make(map[*kiwi.Constraint]bool, len(cns))
+	for _, c := range cns {
+		wanted[c] = true
+	}
+	for _, c := range applied {
+		if !wanted[c] && s.HasConstraint(c) {
+			s.RemoveConstraint(c)
+		}
+	}
+	var first error
+	applied = applied[:0]
+	for _, c := range cns {
+		if !s.HasConstraint(c) {
+			if err := s.AddConstraint(c); err != nil {
+				if first == nil {
+					first = err
+				}
+				continue
+			}
+		}
+		applied = append(applied, c)
+	}
+	return applied, first
+}
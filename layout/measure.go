@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package layout
+
+import (
+	"math"
+
+	"github.com/reactivego/kiwi"
+)
+
+/*
+MeasureFunc returns the height of the content of a box for the width of
+the box, e.g. the height of a paragraph of text wrapped at that width.
+*/
+type MeasureFunc func(width float64) (height float64)
+
+/*
+Measurer sizes boxes to their content. The height of a measured box is
+an edit variable of the solver, a layout pass suggests the height the
+measure callback returns for the width the solver assigned to the box.
+Since the new heights can change the widths of the boxes, a layout pass
+repeats this until the heights no longer change.
+*/
+type Measurer struct {
+	solver     *kiwi.Solver
+	measures   []*measure
+	iterations int
+	tolerance  float64
+}
+
+type measure struct {
+	box      *kiwi.Box
+	fn       MeasureFunc
+	height   float64
+	measured bool
+}
+
+type MeasureOption func(*Measurer)
+
+/*
+WithIterations is an option to set the maximum number of times a layout
+pass measures the boxes. The default is 8.
+*/
+func WithIterations(iterations int) MeasureOption {
+	return func(m *Measurer) {
+		m.iterations = iterations
+	}
+}
+
+/*
+WithTolerance is an option to set how much a height may change between
+measurements and still count as unchanged. The default is 0.5.
+*/
+func WithTolerance(tolerance float64) MeasureOption {
+	return func(m *Measurer) {
+		m.tolerance = tolerance
+	}
+}
+
+// NewMeasurer returns a measurer for boxes laid out by the solver.
+func NewMeasurer(s *kiwi.Solver, options ...MeasureOption) *Measurer {
+	m := &Measurer{solver: s, iterations: 8, tolerance: 0.5}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+/*
+Register adds the height of the box as an edit variable to the solver,
+to be suggested the height the measure callback returns for the width of
+the box. When no strength option is given the edit variable is STRONG.
+
+Returns
+
+	kiwi.DuplicateEditVariable
+The height of the box is already an edit variable of the solver.
+	kiwi.BadRequiredStrength
+The given strength is >= required.
+*/
+func (m *Measurer) Register(box *kiwi.Box, fn MeasureFunc, options ...kiwi.ConstraintOption) error {
+	if err := m.solver.AddEditVariable(box.Height, options...); err != nil {
+		return err
+	}
+	m.measures = append(m.measures, &measure{box: box, fn: fn})
+	return nil
+}
+
+/*
+Unregister removes the height of the box as an edit variable from the
+solver and stops measuring the box.
+
+Returns
+
+	kiwi.UnknownEditVariable
+The box has not been registered.
+	kiwi.StayErrors
+Updating the stays of the solver failed for one or more stays, which
+have been removed. The box is unregistered nonetheless.
+*/
+func (m *Measurer) Unregister(box *kiwi.Box) error {
+	for i, measure := range m.measures {
+		if measure.box == box {
+			m.measures = append(m.measures[:i], m.measures[i+1:]...)
+			return m.solver.RemoveEditVariable(box.Height)
+		}
+	}
+	return kiwi.UnknownEditVariable{Variable: box.Height}
+}
+
+/*
+Layout performs a layout pass. It updates the variables of the solver,
+measures the boxes for their width and suggests the measured heights,
+until no height changes by more than the tolerance or the maximum number
+of iterations is reached. The variables are up to date afterwards and
+the number of iterations performed is returned.
+
+Returns
+
+	NotConverged
+The heights still changed in the last iteration.
+*/
+func (m *Measurer) Layout() (int, error) {
+	for i := 1; i <= m.iterations; i++ {
+		m.solver.UpdateVariables()
+		heights := make(map[*kiwi.Variable]float64)
+		for _, measure := range m.measures {
+			height := measure.fn(measure.box.Width.Value)
+			if !measure.measured || math.Abs(height-measure.height) > m.tolerance {
+				measure.height, measure.measured = height, true
+				heights[measure.box.Height] = height
+			}
+		}
+		if len(heights) == 0 {
+			return i, nil
+		}
+		if err := m.solver.SuggestValues(heights); err != nil {
+			return i, err
+		}
+	}
+	m.solver.UpdateVariables()
+	return m.iterations, NotConverged
+}
@@ -0,0 +1,341 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package layout
+
+import (
+	"github.com/reactivego/kiwi"
+)
+
+// Frame is the position and size of a box after a layout pass.
+type Frame struct {
+	Left, Top, Width, Height float64
+}
+
+/*
+RelateFunc returns the constraints that relate the box of a node to the
+box of its parent, e.g. `node.Inside(parent, 8)`.
+*/
+type RelateFunc func(node, parent *kiwi.Box) []*kiwi.Constraint
+
+/*
+Node is a box in a Tree that owns the constraints on it. The constraints
+of a node are in the solver of the tree while the node is part of the
+tree. Adding a node to the tree adds the constraints of its subtree to
+the solver and removing it removes them again. Moving a node within the
+tree only replaces the constraints that relate it to its parent.
+*/
+type Node struct {
+	Name string
+	Box  *kiwi.Box
+
+	relate   RelateFunc
+	cns      []*kiwi.Constraint
+	related  []*kiwi.Constraint
+	parent   *Node
+	children []*Node
+	tree     *Tree
+	frame    Frame
+	laid     bool
+}
+
+/*
+NewNode returns a node with a box named after the node. The relate
+function gives the constraints between the node and its parent, it is
+called whenever the node is added to a parent and may be nil.
+*/
+func NewNode(name string, relate RelateFunc) *Node {
+	return &Node{Name: name, Box: kiwi.NewBox(name), relate: relate}
+}
+
+// Parent returns the parent of the node, or nil.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Children returns the children of the node in order.
+func (n *Node) Children() []*Node {
+	return append([]*Node(nil), n.children...)
+}
+
+// Frame returns the frame of the node after the last layout pass of its tree.
+func (n *Node) Frame() Frame {
+	return n.frame
+}
+
+// Constraints returns the constraints owned by the node.
+func (n *Node) Constraints() []*kiwi.Constraint {
+	return append(append([]*kiwi.Constraint(nil), n.cns...), n.related...)
+}
+
+func (n *Node) owns(c *kiwi.Constraint) bool {
+	for _, owned := range n.cns {
+		if owned == c {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Constrain makes the node the owner of the constraints. When the node is
+part of a tree, the constraints are added to the solver right away.
+Either all or none of the constraints are added.
+
+Returns
+
+	kiwi.DuplicateConstraint
+The node already owns one of the constraints.
+	kiwi.UnsatisfiableConstraint
+One of the constraints is required and cannot be satisfied.
+*/
+func (n *Node) Constrain(cns ...*kiwi.Constraint) error {
+	for _, c := range cns {
+		if n.owns(c) {
+			return kiwi.DuplicateConstraint{Constraint: c}
+		}
+	}
+	if n.tree != nil {
+		if err := n.tree.solver.AddConstraints(cns); err != nil {
+			return err
+		}
+	}
+	n.cns = append(n.cns, cns...)
+	return nil
+}
+
+/*
+Unconstrain removes the constraints from the node, and from the solver
+when the node is part of a tree.
+
+Returns
+
+	kiwi.UnknownConstraint
+The node does not own one of the constraints. None of the constraints
+are removed in that case.
+*/
+func (n *Node) Unconstrain(cns ...*kiwi.Constraint) error {
+	for _, c := range cns {
+		if !n.owns(c) {
+			return kiwi.UnknownConstraint{Constraint: c}
+		}
+	}
+	for _, c := range cns {
+		for i, owned := range n.cns {
+			if owned == c {
+				n.cns = append(n.cns[:i], n.cns[i+1:]...)
+				break
+			}
+		}
+		if n.tree != nil {
+			n.tree.solver.RemoveConstraint(c)
+		}
+	}
+	return nil
+}
+
+/*
+Append adds the child as the last child of the node. A child that already
+has a parent is moved. Within the same tree only the constraints that
+relate the child to its parent are replaced, otherwise the constraints of
+the subtree of the child are removed from its old tree and added to the
+tree of the node.
+
+Returns
+
+	CyclicNode
+The child is the node itself or one of its ancestors.
+	RootNode
+The child is the root of another tree.
+	kiwi.UnsatisfiableConstraint
+One of the constraints of the subtree is required and cannot be
+satisfied. The child is left without a parent in that case.
+*/
+func (n *Node) Append(child *Node) error {
+	for ancestor := n; ancestor != nil; ancestor = ancestor.parent {
+		if ancestor == child {
+			return CyclicNode{child}
+		}
+	}
+	if child.isRoot() {
+		return RootNode{child}
+	}
+	if child.tree != nil && child.tree == n.tree {
+		child.unlink()
+		child.unrelate()
+		child.parent = n
+		n.children = append(n.children, child)
+		if err := child.relateTo(n); err != nil {
+			child.Detach()
+			return err
+		}
+		child.laid = false
+		return nil
+	}
+	child.Detach()
+	child.parent = n
+	n.children = append(n.children, child)
+	if n.tree != nil {
+		if err := child.attach(n.tree); err != nil {
+			child.Detach()
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Remove removes the child from the node, and the constraints of the
+subtree of the child from the solver.
+
+Returns
+
+	UnknownChild
+The node is not the parent of the child.
+*/
+func (n *Node) Remove(child *Node) error {
+	if child.parent != n {
+		return UnknownChild{child}
+	}
+	child.Detach()
+	return nil
+}
+
+/*
+Detach removes the node from its parent, and the constraints of its
+subtree from the solver. The subtree keeps its constraints, so it can be
+added to a tree again. Detach does nothing on the root of a tree, as the
+root stays part of its tree.
+*/
+func (n *Node) Detach() {
+	if n.isRoot() {
+		return
+	}
+	n.detach()
+	n.unlink()
+	n.unrelate()
+}
+
+// isRoot reports whether the node is the root of a tree.
+func (n *Node) isRoot() bool {
+	return n.tree != nil && n.tree.Root == n
+}
+
+// unlink removes the node from the children of its parent.
+func (n *Node) unlink() {
+	if n.parent == nil {
+		return
+	}
+	siblings := n.parent.children
+	for i, sibling := range siblings {
+		if sibling == n {
+			n.parent.children = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	n.parent = nil
+}
+
+// unrelate removes the constraints that relate the node to its parent.
+func (n *Node) unrelate() {
+	if n.tree != nil {
+		for _, c := range n.related {
+			n.tree.solver.RemoveConstraint(c)
+		}
+	}
+	n.related = nil
+}
+
+// relateTo adds the constraints that relate the node to the parent.
+func (n *Node) relateTo(parent *Node) error {
+	if n.relate == nil {
+		return nil
+	}
+	related := n.relate(n.Box, parent.Box)
+	if n.tree != nil {
+		if err := n.tree.solver.AddConstraints(related); err != nil {
+			return err
+		}
+	}
+	n.related = related
+	return nil
+}
+
+/*
+attach adds the constraints of the subtree of the node to the solver of
+the tree. When a constraint can't be added, the subtree is left detached.
+*/
+func (n *Node) attach(tree *Tree) error {
+	if err := tree.solver.AddConstraints(n.cns); err != nil {
+		return err
+	}
+	n.tree, n.laid = tree, false
+	if n.parent != nil {
+		if err := n.relateTo(n.parent); err != nil {
+			n.detach()
+			return err
+		}
+	}
+	for _, child := range n.children {
+		if err := child.attach(tree); err != nil {
+			n.detach()
+			return err
+		}
+	}
+	return nil
+}
+
+// detach removes the constraints of the subtree of the node from the solver.
+func (n *Node) detach() {
+	if n.tree == nil {
+		return
+	}
+	for _, child := range n.children {
+		child.detach()
+	}
+	for _, c := range n.Constraints() {
+		n.tree.solver.RemoveConstraint(c)
+	}
+	n.tree, n.laid = nil, false
+}
+
+/*
+Tree is a retained tree of nodes laid out by a solver. The root node of
+the tree has no parent and is typically constrained to the size of the
+window.
+*/
+type Tree struct {
+	Root   *Node
+	solver *kiwi.Solver
+}
+
+// NewTree returns a tree with a root node of the given name laid out by the solver.
+func NewTree(s *kiwi.Solver, name string) *Tree {
+	t := &Tree{Root: NewNode(name, nil), solver: s}
+	t.Root.tree = t
+	return t
+}
+
+/*
+Layout updates the variables of the solver and returns the nodes of the
+tree whose frame changed since the previous layout pass, in depth first
+order. Nodes that were added to the tree or moved in it since then are
+always returned.
+*/
+func (t *Tree) Layout() []*Node {
+	t.solver.UpdateVariables()
+	var dirty []*Node
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		b := n.Box
+		frame := Frame{b.Left.Value, b.Top.Value, b.Width.Value, b.Height.Value}
+		if !n.laid || frame != n.frame {
+			n.frame, n.laid = frame, true
+			dirty = append(dirty, n)
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(t.Root)
+	return dirty
+}
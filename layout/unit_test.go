@@ -0,0 +1,306 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package layout
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/reactivego/kiwi"
+)
+
+func container(s *kiwi.Solver, width, height float64) *kiwi.Box {
+	box := kiwi.NewBox("container")
+	s.AddConstraints([]*kiwi.Constraint{
+		box.Left.EqualsConstant(0),
+		box.Top.EqualsConstant(0),
+		box.Width.EqualsConstant(width),
+		box.Height.EqualsConstant(height),
+	})
+	return box
+}
+
+func TestFlex(t *testing.T) {
+	s := kiwi.NewSolver()
+	row := NewFlex(container(s, 300, 50), Row, WithGap(10), WithPadding(5))
+	a, b, c := kiwi.NewBox("a"), kiwi.NewBox("b"), kiwi.NewBox("c")
+	row.Append(&Item{Box: a, Preferred: 50}, &Item{Box: b, Grow: 1}, &Item{Box: c, Grow: 3})
+	assert.Equal(t, nil, row.Update(s), "row.Update(s)")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 5, a.Left.Value, "a.Left")
+	assert.EqualFloat64(t, 50, a.Width.Value, "a.Width")
+	assert.EqualFloat64(t, 65, b.Left.Value, "b.Left")
+	assert.EqualFloat64(t, 55, b.Width.Value, "b.Width")
+	assert.EqualFloat64(t, 130, c.Left.Value, "c.Left")
+	assert.EqualFloat64(t, 165, c.Width.Value, "c.Width")
+	assert.EqualFloat64(t, 5, a.Top.Value, "a.Top")
+	assert.EqualFloat64(t, 40, a.Height.Value, "a.Height")
+
+	// Removing a child only replaces the links to its neighbours
+	cns := row.Constraints()
+	assert.Equal(t, true, row.Remove(b), "row.Remove(b)")
+	assert.Equal(t, false, row.Remove(b), "row.Remove(b)")
+	assert.Equal(t, nil, row.Update(s), "row.Update(s)")
+	s.UpdateVariables()
+	assert.Equal(t, true, s.HasConstraint(cns[0]), "s.HasConstraint(cns[0])")
+	assert.EqualFloat64(t, 65, c.Left.Value, "c.Left")
+	assert.EqualFloat64(t, 230, c.Width.Value, "c.Width")
+	assert.Equal(t, false, s.HasConstraint(cns[4]), "s.HasConstraint(cns[4])")
+
+	// The minimum size wins from the preferred size
+	d := kiwi.NewBox("d")
+	row.Insert(0, &Item{Box: d, Min: 40, Preferred: 10})
+	assert.Equal(t, nil, row.Update(s), "row.Update(s)")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 40, d.Width.Value, "d.Width")
+	assert.EqualFloat64(t, 55, a.Left.Value, "a.Left")
+	assert.EqualFloat64(t, 180, c.Width.Value, "c.Width")
+
+	// Without growing children the space is left at the end
+	row.Remove(c)
+	assert.Equal(t, nil, row.Update(s), "row.Update(s)")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 40, d.Width.Value, "d.Width")
+	assert.EqualFloat64(t, 50, a.Width.Value, "a.Width")
+	assert.Equal(t, len(row.Constraints()), len(s.Constraints())-4, "len(s.Constraints())")
+
+	// The variables of layouts in different containers have different names
+	assert.EqualString(t, "container.grow", row.unit.Name, "row.unit.Name")
+	column := NewFlex(kiwi.NewBox("sidebar"), Column)
+	column.Append(&Item{Box: kiwi.NewBox("e"), Grow: 1})
+	assert.Equal(t, nil, column.Update(s), "column.Update(s)")
+	assert.EqualString(t, "sidebar.grow", column.unit.Name, "column.unit.Name")
+	var file strings.Builder
+	_, err := s.WriteTo(&file)
+	assert.Equal(t, nil, err, "s.WriteTo(&file)")
+}
+
+func TestFlexColumn(t *testing.T) {
+	s := kiwi.NewSolver()
+	column := NewFlex(container(s, 100, 200), Column, WithAlign(AlignCenter))
+	a, b := kiwi.NewBox("a"), kiwi.NewBox("b")
+	column.Append(&Item{Box: a, Grow: 1, Max: 50}, &Item{Box: b, Grow: 1})
+	s.AddConstraints([]*kiwi.Constraint{a.Width.EqualsConstant(20), b.Width.EqualsConstant(60)})
+	assert.Equal(t, nil, column.Update(s), "column.Update(s)")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 40, a.Left.Value, "a.Left")
+	assert.EqualFloat64(t, 20, b.Left.Value, "b.Left")
+	assert.EqualFloat64(t, 50, a.Height.Value, "a.Height")
+	assert.EqualFloat64(t, 50, b.Top.Value, "b.Top")
+	assert.EqualFloat64(t, 150, b.Height.Value, "b.Height")
+
+	// The growing children shrink to make room for a minimum size
+	c := kiwi.NewBox("c")
+	column.Append(&Item{Box: c, Min: 150})
+	assert.Equal(t, nil, column.Update(s), "column.Update(s)")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 25, a.Height.Value, "a.Height")
+	assert.EqualFloat64(t, 25, b.Height.Value, "b.Height")
+	assert.EqualFloat64(t, 50, c.Top.Value, "c.Top")
+}
+
+func TestGrid(t *testing.T) {
+	s := kiwi.NewSolver()
+	grid := NewGrid(container(s, 320, 200),
+		[]Track{Fr(1), Fr(1), Fr(1)},
+		[]Track{MinContent(), Fr(1)},
+		WithGaps(10, 10))
+	header, a, b := kiwi.NewBox("header"), kiwi.NewBox("a"), kiwi.NewBox("b")
+	assert.Equal(t, nil, grid.Place(&Cell{Box: header, ColumnSpan: 3, MinHeight: 30}), "grid.Place(header)")
+	assert.Equal(t, nil, grid.Place(&Cell{Box: a, Row: 1}), "grid.Place(a)")
+	assert.Equal(t, nil, grid.Place(&Cell{Box: b, Column: 1, Row: 1, ColumnSpan: 2}), "grid.Place(b)")
+	assert.Equal(t, nil, grid.Update(s), "grid.Update(s)")
+	s.UpdateVariables()
+
+	// Equal fractions divide the space evenly
+	for i, line := range []float64{0, 110, 220, 320} {
+		assert.EqualFloat64(t, line, grid.Columns[i].Value, "grid.Columns[%d]", i)
+	}
+	assert.EqualFloat64(t, 100, a.Width.Value, "a.Width")
+
+	// Spans include the gaps between their tracks
+	assert.EqualFloat64(t, 320, header.Width.Value, "header.Width")
+	assert.EqualFloat64(t, 110, b.Left.Value, "b.Left")
+	assert.EqualFloat64(t, 210, b.Width.Value, "b.Width")
+
+	// The min-content row fits the header
+	assert.EqualFloat64(t, 30, header.Height.Value, "header.Height")
+	assert.EqualFloat64(t, 40, a.Top.Value, "a.Top")
+	assert.EqualFloat64(t, 160, b.Height.Value, "b.Height")
+
+	assert.Equal(t, true, grid.Remove(header), "grid.Remove(header)")
+	assert.Equal(t, nil, grid.Update(s), "grid.Update(s)")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 10, a.Top.Value, "a.Top")
+	assert.EqualFloat64(t, 190, a.Height.Value, "a.Height")
+
+	err := grid.Place(&Cell{Box: kiwi.NewBox("c"), Column: 2, ColumnSpan: 2})
+	assert.Equal(t, true, errors.As(err, &CellOutsideGrid{}), "errors.As(err, &CellOutsideGrid{})")
+}
+
+func TestGridTracks(t *testing.T) {
+	s := kiwi.NewSolver()
+	grid := NewGrid(container(s, 250, 100), []Track{Fixed(50), Fr(1), Fr(3)}, []Track{Fixed(40), Fixed(40)})
+	a, b := kiwi.NewBox("a"), kiwi.NewBox("b")
+	grid.Place(&Cell{Box: a, Column: 1, Row: 1})
+	grid.Place(&Cell{Box: b, Column: 2, RowSpan: 2})
+	assert.Equal(t, nil, grid.Update(s), "grid.Update(s)")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 50, a.Left.Value, "a.Left")
+	assert.EqualFloat64(t, 50, a.Width.Value, "a.Width")
+	assert.EqualFloat64(t, 40, a.Top.Value, "a.Top")
+	assert.EqualFloat64(t, 150, b.Width.Value, "b.Width")
+	assert.EqualFloat64(t, 80, b.Height.Value, "b.Height")
+	assert.EqualFloat64(t, 80, grid.Rows[2].Value, "grid.Rows[2]")
+	assert.EqualString(t, "container.column0", grid.Columns[0].Name, "grid.Columns[0].Name")
+	assert.EqualString(t, "container.row.fr", grid.rows.unit.Name, "grid.rows.unit.Name")
+}
+
+func TestMeasurer(t *testing.T) {
+	// A paragraph of 600 units of text in lines of 10 high
+	paragraph := func(width float64) float64 {
+		return math.Ceil(600/width) * 10
+	}
+	s := kiwi.NewSolver()
+	column := NewFlex(container(s, 300, 400), Column, WithPadding(10))
+	text := kiwi.NewBox("text")
+	column.Append(&Item{Box: text})
+	column.Update(s)
+	m := NewMeasurer(s)
+	assert.Equal(t, nil, m.Register(text, paragraph), "m.Register(text, paragraph)")
+	iterations, err := m.Layout()
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, 2, iterations, "iterations")
+	assert.EqualFloat64(t, 280, text.Width.Value, "text.Width")
+	assert.EqualFloat64(t, 30, text.Height.Value, "text.Height")
+
+	// A layout pass only suggests heights that changed
+	iterations, err = m.Layout()
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, 1, iterations, "iterations")
+
+	// The width depends on the height, so it takes a few iterations
+	box := kiwi.NewBox("box")
+	s.AddConstraint(box.Width.AddVariable(box.Height).EqualsConstant(200))
+	area := func(width float64) float64 {
+		return 6000 / width
+	}
+	m.Register(box, area)
+	iterations, err = m.Layout()
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, true, iterations > 2 && iterations <= 8, "iterations %d", iterations)
+	assert.Equal(t, true, math.Abs(box.Height.Value-area(box.Width.Value)) <= 0.5, "box.Height %g", box.Height.Value)
+
+	// Oscillating measurements end after the maximum number of iterations
+	m = NewMeasurer(kiwi.NewSolver(), WithIterations(3))
+	flip := kiwi.NewBox("flip")
+	m.Register(flip, func(width float64) float64 { return 50 - flip.Height.Value })
+	iterations, err = m.Layout()
+	assert.Equal(t, NotConverged, err, "err")
+	assert.Equal(t, 3, iterations, "iterations")
+
+	assert.Equal(t, nil, m.Unregister(flip), "m.Unregister(flip)")
+	assert.Equal(t, kiwi.UnknownEditVariable{Variable: flip.Height}, m.Unregister(flip), "m.Unregister(flip)")
+}
+
+func TestTree(t *testing.T) {
+	offset := func(left, top float64) RelateFunc {
+		return func(node, parent *kiwi.Box) []*kiwi.Constraint {
+			return []*kiwi.Constraint{
+				node.Left.EqualsExpression(parent.Left.AddConstant(left)),
+				node.Top.EqualsExpression(parent.Top.AddConstant(top)),
+			}
+		}
+	}
+	names := func(nodes []*Node) string {
+		var names []string
+		for _, n := range nodes {
+			names = append(names, n.Name)
+		}
+		return strings.Join(names, " ")
+	}
+	s := kiwi.NewSolver()
+	tree := NewTree(s, "root")
+	root := tree.Root
+	root.Constrain(root.Box.Left.EqualsConstant(0), root.Box.Top.EqualsConstant(0), root.Box.Width.EqualsConstant(400), root.Box.Height.EqualsConstant(300))
+	panel, button := NewNode("panel", offset(10, 10)), NewNode("button", offset(5, 5))
+	panel.Constrain(panel.Box.Width.EqualsConstant(100), panel.Box.Height.EqualsConstant(50))
+	button.Constrain(button.Box.Width.EqualsConstant(20), button.Box.Height.EqualsConstant(10))
+	assert.Equal(t, nil, panel.Append(button), "panel.Append(button)")
+	assert.Equal(t, 4, len(s.Constraints()), "len(s.Constraints())")
+	assert.Equal(t, nil, root.Append(panel), "root.Append(panel)")
+	assert.Equal(t, 12, len(s.Constraints()), "len(s.Constraints())")
+	assert.EqualString(t, "root panel button", names(tree.Layout()), "tree.Layout()")
+	assert.Equal(t, Frame{15, 15, 20, 10}, button.Frame(), "button.Frame()")
+	assert.EqualString(t, "", names(tree.Layout()), "tree.Layout()")
+
+	// Moving a node only replaces the constraints relating it to its parent
+	owned := panel.Constraints()
+	assert.Equal(t, nil, root.Append(button), "root.Append(button)")
+	assert.Equal(t, 12, len(s.Constraints()), "len(s.Constraints())")
+	for _, c := range owned {
+		assert.Equal(t, true, s.HasConstraint(c), "s.HasConstraint(%v)", c)
+	}
+	assert.EqualString(t, "button", names(tree.Layout()), "tree.Layout()")
+	assert.Equal(t, Frame{5, 5, 20, 10}, button.Frame(), "button.Frame()")
+	assert.Equal(t, root, button.Parent(), "button.Parent()")
+
+	// Removing a subtree removes its constraints, adding it again restores them
+	assert.Equal(t, nil, root.Remove(panel), "root.Remove(panel)")
+	assert.Equal(t, 8, len(s.Constraints()), "len(s.Constraints())")
+	assert.Equal(t, nil, panel.Append(button), "panel.Append(button)")
+	assert.Equal(t, 4, len(s.Constraints()), "len(s.Constraints())")
+	assert.Equal(t, nil, root.Append(panel), "root.Append(panel)")
+	assert.Equal(t, 12, len(s.Constraints()), "len(s.Constraints())")
+	assert.EqualString(t, "panel button", names(tree.Layout()), "tree.Layout()")
+	assert.Equal(t, Frame{15, 15, 20, 10}, button.Frame(), "button.Frame()")
+
+	// Constraints added to a node in the tree are solved incrementally
+	assert.Equal(t, nil, button.Unconstrain(button.Constraints()[0]), "button.Unconstrain")
+	assert.Equal(t, nil, button.Constrain(button.Box.Width.EqualsConstant(30)), "button.Constrain")
+	assert.EqualString(t, "button", names(tree.Layout()), "tree.Layout()")
+
+	err := panel.Constrain(panel.Box.Width.EqualsConstant(200))
+	assert.Equal(t, true, errors.As(err, &kiwi.UnsatisfiableConstraint{}), "errors.As(err, &kiwi.UnsatisfiableConstraint{})")
+	assert.Equal(t, 4, len(panel.Constraints()), "len(panel.Constraints())")
+	c := panel.Constraints()[0]
+	assert.Equal(t, kiwi.DuplicateConstraint{Constraint: c}, panel.Constrain(c), "panel.Constrain(c)")
+	assert.Equal(t, CyclicNode{root}, button.Append(root), "button.Append(root)")
+	assert.Equal(t, UnknownChild{button}, root.Remove(button), "root.Remove(button)")
+
+	// The root stays part of its tree
+	root.Detach()
+	assert.Equal(t, 12, len(s.Constraints()), "len(s.Constraints())")
+	other := NewNode("other", offset(0, 0))
+	assert.Equal(t, nil, root.Append(other), "root.Append(other)")
+	assert.Equal(t, 14, len(s.Constraints()), "len(s.Constraints())")
+	assert.Equal(t, RootNode{root}, NewTree(kiwi.NewSolver(), "window").Root.Append(root), "Append(root)")
+	assert.Equal(t, tree, root.tree, "root.tree")
+}
+
+var assert = struct {
+	Equal        func(t *testing.T, exp, got interface{}, msg string, info ...interface{})
+	EqualFloat64 func(t *testing.T, exp, got float64, msg string, info ...interface{})
+	EqualString  func(t *testing.T, exp, got string, msg string, info ...interface{})
+}{
+	Equal: func(t *testing.T, exp, got interface{}, msg string, info ...interface{}) {
+		t.Helper()
+		if exp != got {
+			t.Errorf(msg+" expected %#v got %#v", append(append(info, exp), got)...)
+		}
+	},
+	EqualFloat64: func(t *testing.T, exp, got float64, msg string, info ...interface{}) {
+		t.Helper()
+		if !kiwi.NearZero(got - exp) {
+			t.Errorf(msg+" expected %g got %g", append(append(info, exp), got)...)
+		}
+	},
+	EqualString: func(t *testing.T, exp, got string, msg string, info ...interface{}) {
+		t.Helper()
+		if exp != got {
+			t.Errorf(msg+" expected %#q got %#q", append(append(info, exp), got)...)
+		}
+	},
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type token int
+
+const (
+	tokILLEGAL token = iota
+	tokEOF
+	tokIDENT
+	tokINT
+	tokFLOAT
+	tokADD
+	tokSUB
+	tokMUL
+	tokQUO
+	tokEQL
+	tokLEQ
+	tokGEQ
+	tokLPAREN
+	tokRPAREN
+	tokPERIOD
+	tokASSIGN
+	tokPIPE
+	tokAT
+	tokBANG
+	tokLBRACK
+	tokRBRACK
+	tokCOLON
+	tokCOMMA
+)
+
+func (t token) String() string {
+	return [...]string{"ILLEGAL", "EOF", "IDENT", "INT", "FLOAT", "+", "-", "*", "/", "==", "<=", ">=", "(", ")", ".", "=", "|", "@", "!", "[", "]", ":", ","}[t]
+}
+
+func (t token) isComparison() bool {
+	return t == tokEQL || t == tokLEQ || t == tokGEQ
+}
+
+/*
+lexer splits constraint text into tokens.
+
+Every call to next scans the next token and records its kind, its
+literal text and its byte offset in the source.
+*/
+type lexer struct {
+	src    string
+	offset int
+
+	tok token
+	lit string
+	pos int
+}
+
+func newLexer(src string) *lexer {
+	l := &lexer{src: src}
+	l.next()
+	return l
+}
+
+func (l *lexer) peek() rune {
+	r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+	return r
+}
+
+/*
+skipWhitespace skips whitespace and comments. A comment starts with
+`#` or `//` and runs until the end of the line.
+*/
+func (l *lexer) skipWhitespace() {
+	for l.offset < len(l.src) {
+		if strings.HasPrefix(l.src[l.offset:], "#") || strings.HasPrefix(l.src[l.offset:], "//") {
+			if eol := strings.IndexByte(l.src[l.offset:], '\n'); eol >= 0 {
+				l.offset += eol
+			} else {
+				l.offset = len(l.src)
+			}
+			continue
+		}
+		r, w := utf8.DecodeRuneInString(l.src[l.offset:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		l.offset += w
+	}
+}
+
+/*
+next scans the next token.
+
+At the end of the source the token is tokEOF. A character that can't
+start a token results in tokILLEGAL with the character as literal.
+*/
+func (l *lexer) next() {
+	l.skipWhitespace()
+	l.pos = l.offset
+	if l.offset >= len(l.src) {
+		l.tok, l.lit = tokEOF, ""
+		return
+	}
+	r, w := utf8.DecodeRuneInString(l.src[l.offset:])
+	switch {
+	case isLetter(r):
+		for l.offset < len(l.src) && (isLetter(l.peek()) || isDigit(l.peek())) {
+			_, w := utf8.DecodeRuneInString(l.src[l.offset:])
+			l.offset += w
+		}
+		l.tok = tokIDENT
+	case isDigit(r) || (r == '.' && l.offset+1 < len(l.src) && isDigit(rune(l.src[l.offset+1]))):
+		l.tok = l.scanNumber()
+	default:
+		l.offset += w
+		l.tok = tokILLEGAL
+		switch r {
+		case '+':
+			l.tok = tokADD
+		case '-':
+			l.tok = tokSUB
+		case '*':
+			l.tok = tokMUL
+		case '/':
+			l.tok = tokQUO
+		case '(':
+			l.tok = tokLPAREN
+		case ')':
+			l.tok = tokRPAREN
+		case '.':
+			l.tok = tokPERIOD
+		case '[':
+			l.tok = tokLBRACK
+		case ']':
+			l.tok = tokRBRACK
+		case ':':
+			l.tok = tokCOLON
+		case ',':
+			l.tok = tokCOMMA
+		case '|':
+			l.tok = tokPIPE
+		case '@':
+			l.tok = tokAT
+		case '!':
+			l.tok = tokBANG
+		case '=', '<', '>':
+			if l.peek() == '=' {
+				l.offset++
+				l.tok = map[rune]token{'=': tokEQL, '<': tokLEQ, '>': tokGEQ}[r]
+			} else if r == '=' {
+				l.tok = tokASSIGN
+			}
+		}
+	}
+	l.lit = l.src[l.pos:l.offset]
+}
+
+func (l *lexer) scanNumber() token {
+	tok := tokINT
+	digits := func() {
+		for l.offset < len(l.src) && isDigit(l.peek()) {
+			l.offset++
+		}
+	}
+	digits()
+	if l.offset < len(l.src) && l.src[l.offset] == '.' {
+		tok = tokFLOAT
+		l.offset++
+		digits()
+	}
+	if l.offset < len(l.src) && strings.ContainsRune("eE", l.peek()) {
+		exp := l.offset
+		l.offset++
+		if l.offset < len(l.src) && strings.ContainsRune("+-", l.peek()) {
+			l.offset++
+		}
+		if l.offset < len(l.src) && isDigit(l.peek()) {
+			tok = tokFLOAT
+			digits()
+		} else {
+			// not an exponent after all, e.g. "2e" in "2em"
+			l.offset = exp
+		}
+	}
+	return tok
+}
+
+func isLetter(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
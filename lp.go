@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+lpModel is the linear program equivalent to the problem a solver solves.
+
+Every required constraint becomes a row as is. A constraint that is not
+required gets error columns that absorb its violation: a LE constraint
+`expr <= rhs` becomes `expr - err <= rhs`, a GE constraint gets `+ err`
+and an EQ constraint gets both a `- plus` and a `+ minus` column. The
+error columns are non-negative and weighted by the strength of their
+constraint in the objective, which is minimized. Stays and edit
+variables are constraints `v == value` with their own strength.
+*/
+type lpModel struct {
+	columns   []string
+	comments  []string
+	free      int
+	objective []lpTerm
+	rows      []lpRow
+}
+
+type lpTerm struct {
+	column      int
+	coefficient float64
+}
+
+type lpRow struct {
+	name  string
+	terms []lpTerm
+	op    Operator
+	rhs   float64
+}
+
+func (s *Solver) lpModel() *lpModel {
+	m := &lpModel{}
+	used := make(map[string]bool)
+	column := func(name string) int {
+		unique := name
+		for i := 2; used[strings.ToLower(unique)]; i++ {
+			unique = fmt.Sprintf("%s_%d", name, i)
+		}
+		used[strings.ToLower(unique)] = true
+		m.columns = append(m.columns, unique)
+		return len(m.columns) - 1
+	}
+
+	vars := s.Variables()
+	columns := make(map[*Variable]int, len(vars))
+	for _, v := range vars {
+		columns[v] = column(lpName(v.Name))
+		if m.columns[columns[v]] != v.Name {
+			m.comments = append(m.comments, m.columns[columns[v]]+" is "+v.Name)
+		}
+	}
+	m.free = len(m.columns)
+
+	add := func(name string, terms []Term, op Operator, rhs float64, strength Strength) {
+		row := lpRow{name: name, op: op, rhs: rhs}
+		for _, t := range terms {
+			if !NearZero(t.Coefficient) {
+				row.terms = append(row.terms, lpTerm{columns[t.Variable], t.Coefficient})
+			}
+		}
+		if strength < REQUIRED {
+			errorColumn := func(suffix string, coefficient float64) {
+				c := column(name + suffix)
+				row.terms = append(row.terms, lpTerm{c, coefficient})
+				m.objective = append(m.objective, lpTerm{c, float64(strength)})
+			}
+			switch op {
+			case LE:
+				errorColumn("_err", -1)
+			case GE:
+				errorColumn("_err", 1)
+			case EQ:
+				errorColumn("_plus", -1)
+				errorColumn("_minus", 1)
+			}
+		}
+		m.rows = append(m.rows, row)
+	}
+	for i, c := range s.Constraints() {
+		add(fmt.Sprintf("c%d", i+1), c.Expression.Terms, c.Operator, -c.Expression.Constant, c.Strength)
+	}
+	for i, stay := range s.Stays() {
+		add(fmt.Sprintf("stay%d", i+1), []Term{{stay.Variable, 1}}, EQ, stay.Value, stay.Strength)
+	}
+	for i, edit := range s.EditVariables() {
+		add(fmt.Sprintf("edit%d", i+1), []Term{{edit.Variable, 1}}, EQ, edit.Value, edit.Strength)
+	}
+	return m
+}
+
+/*
+lpName turns a variable name into a name that is valid in both the LP
+and the MPS format. Characters other than letters, digits, `_` and `.`
+are replaced by `_`, and names that don't start with a letter or that
+are keywords of the LP format get a `v_` prefix.
+*/
+func lpName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 128 && (isLetter(r) || isDigit(r) || r == '.') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	lp := b.String()
+	keywords := map[string]bool{
+		"st": true, "subject": true, "such": true, "to": true, "bound": true, "bounds": true,
+		"free": true, "inf": true, "infinity": true, "end": true, "min": true, "max": true,
+		"minimize": true, "maximize": true, "general": true, "generals": true,
+		"binary": true, "binaries": true, "integer": true, "obj": true,
+	}
+	if lp == "" || !isLetter(rune(lp[0])) || keywords[strings.ToLower(lp)] {
+		lp = "v_" + lp
+	}
+	return lp
+}
+
+/*
+WriteLP writes the problem the solver solves as a linear program in the
+CPLEX LP format, so the solution can be checked with other LP solvers.
+
+The solver variables are free, constraints that are not required are
+relaxed by error variables that are weighted by the strength of the
+constraint in the objective. Stays and edit variables are written as
+constraints that hold the variable at its anchored or suggested value.
+Variable names are adjusted to valid LP names where needed, the original
+names are given in comments.
+*/
+func (s *Solver) WriteLP(w io.Writer) error {
+	m := s.lpModel()
+	var b strings.Builder
+	b.WriteString("\\ kiwi solver\n")
+	for _, comment := range m.comments {
+		b.WriteString("\\ " + comment + "\n")
+	}
+	b.WriteString("Minimize\n obj:")
+	b.WriteString(m.formatTerms(m.objective))
+	b.WriteString("\nSubject To\n")
+	for _, row := range m.rows {
+		if len(row.terms) == 0 {
+			b.WriteString("\\ " + row.name + " has no variables\n")
+			continue
+		}
+		op := map[Operator]string{LE: "<=", GE: ">=", EQ: "="}[row.op]
+		b.WriteString(" " + row.name + ":" + m.formatTerms(row.terms) + " " + op + " " + formatNumber(row.rhs) + "\n")
+	}
+	if m.free > 0 {
+		b.WriteString("Bounds\n")
+		for _, name := range m.columns[:m.free] {
+			b.WriteString(" " + name + " free\n")
+		}
+	}
+	b.WriteString("End\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (m *lpModel) formatTerms(terms []lpTerm) string {
+	var b strings.Builder
+	for _, t := range terms {
+		if t.coefficient < 0 {
+			b.WriteString(" - ")
+		} else {
+			b.WriteString(" + ")
+		}
+		if c := t.coefficient; c != 1 && c != -1 {
+			if c < 0 {
+				c = -c
+			}
+			b.WriteString(formatNumber(c) + " ")
+		}
+		b.WriteString(m.columns[t.column])
+	}
+	return b.String()
+}
+
+/*
+WriteMPS writes the problem the solver solves as a linear program in the
+free MPS format. The program is the same as the one written by WriteLP.
+*/
+func (s *Solver) WriteMPS(w io.Writer) error {
+	m := s.lpModel()
+	var b strings.Builder
+	b.WriteString("* kiwi solver\n")
+	for _, comment := range m.comments {
+		b.WriteString("* " + comment + "\n")
+	}
+	b.WriteString("NAME kiwi\nROWS\n N obj\n")
+	entries := make([][]string, len(m.columns))
+	for _, t := range m.objective {
+		entries[t.column] = append(entries[t.column], "obj "+formatNumber(t.coefficient))
+	}
+	for _, row := range m.rows {
+		if len(row.terms) == 0 {
+			continue
+		}
+		b.WriteString(" " + map[Operator]string{LE: "L", GE: "G", EQ: "E"}[row.op] + " " + row.name + "\n")
+		for _, t := range row.terms {
+			entries[t.column] = append(entries[t.column], row.name+" "+formatNumber(t.coefficient))
+		}
+	}
+	b.WriteString("COLUMNS\n")
+	for i, name := range m.columns {
+		for _, entry := range entries[i] {
+			b.WriteString(" " + name + " " + entry + "\n")
+		}
+	}
+	b.WriteString("RHS\n")
+	for _, row := range m.rows {
+		if len(row.terms) > 0 && !NearZero(row.rhs) {
+			b.WriteString(" RHS " + row.name + " " + formatNumber(row.rhs) + "\n")
+		}
+	}
+	b.WriteString("BOUNDS\n")
+	for _, name := range m.columns[:m.free] {
+		b.WriteString(" FR BND " + name + "\n")
+	}
+	b.WriteString("ENDATA\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+/*
+Namespace resolves the names used in constraint text to variables.
+
+Names may be dotted and indexed paths like `C0.X` or `col[3].left`. The
+name passed to Lookup is always in canonical form, i.e. without any
+whitespace and with indexes in decimal without leading zeros.
+*/
+type Namespace interface {
+	Lookup(name string) (*Variable, bool)
+}
+
+// Names is a Namespace that maps names to variables.
+type Names map[string]*Variable
+
+var _ Namespace = Names{}
+
+// NamesOf returns a namespace with the given variables under their own name.
+func NamesOf(vars []*Variable) Names {
+	names := make(Names, len(vars))
+	for _, v := range vars {
+		names[v.Name] = v
+	}
+	return names
+}
+
+func (n Names) Lookup(name string) (*Variable, bool) {
+	v, present := n[name]
+	return v, present
+}
+
+/*
+ValueNamespace is implemented by namespaces that also define named
+constants and parameters. Names that resolve to a constant or parameter
+take precedence over variables with the same name.
+*/
+type ValueNamespace interface {
+	LookupConst(name string) (float64, bool)
+	LookupParam(name string) (*Parameter, bool)
+}
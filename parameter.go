@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+Parameter is a named value that appears in the constant of constraints,
+e.g. `gutter` in `a.right + gutter <= b.left`.
+
+Unlike a variable, a parameter is never solved for. When constraint text
+referring to a parameter is evaluated, the current value of the parameter
+is folded into the constant of the constraint and the constraint records
+the coefficient of the parameter in it. Changing the parameter through
+Solver.SetParameter then updates the constant of every such constraint in
+the solver, without reparsing or re-adding the constraints.
+
+A parameter may only appear linearly in constraint text, e.g.
+`2 * gutter` is allowed but `gutter * x` is not.
+*/
+type Parameter struct {
+	Name     string
+	value    float64
+	variable *Variable
+}
+
+/*
+binding records a parameter in the constant of a constraint, along with
+the value of the parameter last folded into that constant.
+*/
+type binding struct {
+	parameter   *Parameter
+	coefficient float64
+	value       float64
+}
+
+// NewParameter returns a parameter with the given name and value.
+func NewParameter(name string, value float64) *Parameter {
+	return &Parameter{Name: name, value: value, variable: NewVariable(name)}
+}
+
+// Value returns the current value of the parameter.
+func (p *Parameter) Value() float64 {
+	return p.value
+}
+
+func (p *Parameter) String() string {
+	return fmt.Sprintf("%s = %v", p.Name, p.value)
+}
+
+/*
+bind folds the current value of the parameter into the constant of the
+constraint and records the parameter on the constraint for later updates.
+*/
+func (p *Parameter) bind(c *Constraint, coefficient float64) {
+	c.Expression.Constant += coefficient * p.value
+	c.bindings = append(c.bindings, &binding{p, coefficient, p.value})
+}
+
+/*
+bind makes the parameters of the constraint update the constraint, it is
+called when the constraint is added to the solver.
+*/
+func (s *Solver) bind(c *Constraint) {
+	for _, b := range c.bindings {
+		if s.params[b.parameter] == nil {
+			s.params[b.parameter] = map[*Constraint]*binding{}
+		}
+		s.params[b.parameter][c] = b
+	}
+}
+
+// unbind forgets the parameters of a constraint removed from the solver.
+func (s *Solver) unbind(c *Constraint) {
+	for _, b := range c.bindings {
+		delete(s.params[b.parameter], c)
+		if len(s.params[b.parameter]) == 0 {
+			delete(s.params, b.parameter)
+		}
+	}
+}
+
+/*
+SetParameter changes the value of the parameter and updates the constant
+of every constraint in the solver that was created with the parameter,
+using SetConstant in the order the constraints were added to the solver.
+
+Constraints that are not in the solver keep their constant. They are
+updated by a call to SetParameter on the solver they are added to.
+
+Returns
+
+	UnsatisfiableConstraint
+A required constraint cannot be satisfied with the new value. The
+constraint keeps its previous constant, the remaining constraints are
+still updated. The first such error is returned.
+*/
+func (s *Solver) SetParameter(p *Parameter, value float64) error {
+	p.value = value
+	bound := make([]*Constraint, 0, len(s.params[p]))
+	for c := range s.params[p] {
+		bound = append(bound, c)
+	}
+	sort.Slice(bound, func(i, j int) bool {
+		return s.cns[bound[i]].marker.id < s.cns[bound[j]].marker.id
+	})
+	var first error
+	for _, c := range bound {
+		b := s.params[p][c]
+		if b.value == value {
+			continue
+		}
+		constant := c.Expression.Constant + b.coefficient*(value-b.value)
+		if err := s.SetConstant(c, constant); err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		b.value = value
+	}
+	return first
+}
@@ -0,0 +1,447 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+The constraint language is parsed by a recursive descent parser
+implementing the following grammar:
+
+	Document   = { [ Label ] Statement newline } .
+	Label      = identifier ":" .
+	Statement  = Comparison [ Annotation ] .
+	Annotation = ( "|" [ "strength" "=" ] | "@" | "!" ) Strength .
+	Strength   = Number | identifier [ "(" Number ")" ] .
+	Comparison = Sum { ( "==" | "<=" | ">=" ) Sum } .
+	Sum        = Product { ( "+" | "-" ) Product } .
+	Product    = Unary { ( "*" | "/" ) Unary } .
+	Unary      = ( "+" | "-" ) Unary | Primary .
+	Primary    = Number | Name | Call | "(" Sum ")" .
+	Call       = identifier "(" Sum { "," Sum } ")" .
+	Name       = identifier { "." identifier | "[" Int "]" } .
+
+A solver file, see LoadSolver, is a document that may also contain
+declarations of variables, stays and edit variables:
+
+	File        = { ( Declaration | [ Label ] Statement ) newline } .
+	Declaration = ( "var" | "stay" | "edit" ) Name [ "=" [ "-" ] Number ] [ Annotation ] .
+
+Comments start with `#` or `//` and run until the end of the line.
+Every line of a document holds at most one statement.
+
+A comparison with more than one operator is a chained comparison,
+e.g. `0 <= x <= 100`, which stands for one constraint per operator.
+
+The identifier of a strength is one of `required`, `strong`, `medium`,
+`weak` or `optional` in any case. A weight can only be given for the
+strong, medium and weak strengths, e.g. `x == 100 | medium(250)`. The
+`strength =` prefix is accepted so the output of Constraint.String
+can be parsed back.
+*/
+
+type node interface {
+	Pos() int
+}
+
+type binaryExpr struct {
+	x     node
+	opPos int
+	op    token
+	y     node
+}
+
+type unaryExpr struct {
+	opPos int
+	op    token
+	x     node
+}
+
+type ident struct {
+	namePos int
+	name    string
+}
+
+type basicLit struct {
+	valuePos int
+	kind     token
+	value    string
+}
+
+type callExpr struct {
+	namePos int
+	name    string
+	lparen  int
+	args    []node
+	rparen  int
+}
+
+type parenExpr struct {
+	lparen int
+	x      node
+	rparen int
+}
+
+func (e *binaryExpr) Pos() int { return e.x.Pos() }
+func (e *unaryExpr) Pos() int  { return e.opPos }
+func (e *ident) Pos() int      { return e.namePos }
+func (e *basicLit) Pos() int   { return e.valuePos }
+func (e *callExpr) Pos() int   { return e.namePos }
+func (e *parenExpr) Pos() int  { return e.lparen }
+
+type declaration struct {
+	keyword  string
+	namePos  int
+	name     string
+	value    *float64
+	strength *Strength
+}
+
+type parser struct {
+	*lexer
+}
+
+func newParser(src string) *parser {
+	return &parser{newLexer(src)}
+}
+
+/*
+errorf returns a syntax error for the token at the given position.
+*/
+func (p *parser) errorf(pos int, msg string) error {
+	token := ""
+	if pos == p.pos {
+		token = p.lit
+	} else if pos < len(p.src) {
+		l := newLexer(p.src[pos:])
+		token = l.lit
+	}
+	return newParseError(p.src, pos, token, msg, nil)
+}
+
+func (p *parser) unexpected(expected string) error {
+	switch p.tok {
+	case tokEOF:
+		return p.errorf(p.pos, "expected "+expected+", found end of input")
+	case tokILLEGAL:
+		return p.errorf(p.pos, "illegal character "+p.lit)
+	default:
+		return p.errorf(p.pos, "expected "+expected+", found "+p.lit)
+	}
+}
+
+func (p *parser) expect(tok token) (int, error) {
+	pos := p.pos
+	if p.tok != tok {
+		return pos, p.unexpected(tok.String())
+	}
+	p.next()
+	return pos, nil
+}
+
+/*
+parseLabel parses the optional label in front of a statement, as in
+`header_gap: header.bottom + 8 == body.top`.
+*/
+func (p *parser) parseLabel() string {
+	if p.tok != tokIDENT {
+		return ""
+	}
+	save := *p.lexer
+	label := p.lit
+	p.next()
+	if p.tok != tokCOLON {
+		*p.lexer = save
+		return ""
+	}
+	p.next()
+	return label
+}
+
+/*
+parseStatement parses the complete source as a single comparison with
+an optional strength annotation.
+*/
+func (p *parser) parseStatement() (*AST, error) {
+	x, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	a := &AST{node: x, src: p.src}
+	if p.tok == tokPIPE || p.tok == tokAT || p.tok == tokBANG {
+		strength, err := p.parseAnnotation()
+		if err != nil {
+			return nil, err
+		}
+		a.strength = &strength
+	}
+	if p.tok != tokEOF {
+		return nil, p.unexpected("operator")
+	}
+	return a, nil
+}
+
+/*
+parseDeclaration parses a declaration like `var x = 10` or `stay x | weak`.
+It returns a nil declaration, without consuming any tokens, when the
+source doesn't start with a keyword followed by a name.
+*/
+func (p *parser) parseDeclaration() (*declaration, error) {
+	if p.tok != tokIDENT || (p.lit != "var" && p.lit != "stay" && p.lit != "edit") {
+		return nil, nil
+	}
+	save := *p.lexer
+	d := &declaration{keyword: p.lit}
+	p.next()
+	if p.tok != tokIDENT {
+		*p.lexer = save
+		return nil, nil
+	}
+	d.namePos = p.pos
+	x, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	d.name = x.(*ident).name
+	if p.tok == tokASSIGN {
+		p.next()
+		sign := 1.0
+		if p.tok == tokSUB {
+			sign = -1
+			p.next()
+		}
+		if p.tok != tokINT && p.tok != tokFLOAT {
+			return nil, p.unexpected("value")
+		}
+		value, err := strconv.ParseFloat(p.lit, 64)
+		if err != nil {
+			return nil, p.errorf(p.pos, "invalid value "+p.lit)
+		}
+		value *= sign
+		d.value = &value
+		p.next()
+	}
+	if d.keyword != "var" && (p.tok == tokPIPE || p.tok == tokAT || p.tok == tokBANG) {
+		strength, err := p.parseAnnotation()
+		if err != nil {
+			return nil, err
+		}
+		d.strength = &strength
+	}
+	if p.tok != tokEOF {
+		return nil, p.unexpected("end of line")
+	}
+	return d, nil
+}
+
+func (p *parser) parseAnnotation() (Strength, error) {
+	marker := p.tok
+	p.next()
+	if marker == tokPIPE && p.tok == tokIDENT && strings.EqualFold(p.lit, "strength") {
+		p.next()
+		if _, err := p.expect(tokASSIGN); err != nil {
+			return 0, err
+		}
+	}
+	return p.parseStrength()
+}
+
+/*
+parseStrength parses a strength like `strong`, `medium(250)` or `1000`.
+*/
+func (p *parser) parseStrength() (Strength, error) {
+	switch p.tok {
+	case tokINT, tokFLOAT:
+		value, err := strconv.ParseFloat(p.lit, 64)
+		if err != nil {
+			return 0, p.errorf(p.pos, "invalid strength "+p.lit)
+		}
+		p.next()
+		return Strength(value), nil
+	case tokIDENT:
+		namePos, name := p.pos, strings.ToLower(p.lit)
+		p.next()
+		strengths := map[string]func(...float64) Strength{"strong": Strong, "medium": Medium, "weak": Weak}
+		switch name {
+		case "required":
+			return REQUIRED, nil
+		case "optional":
+			return OPTIONAL, nil
+		case "strong", "medium", "weak":
+			if p.tok != tokLPAREN {
+				return strengths[name](), nil
+			}
+			p.next()
+			weightPos, weight := p.pos, p.lit
+			if p.tok != tokINT && p.tok != tokFLOAT {
+				return 0, p.unexpected("weight")
+			}
+			w, err := strconv.ParseFloat(weight, 64)
+			if err != nil {
+				return 0, p.errorf(weightPos, "invalid weight "+weight)
+			}
+			p.next()
+			if _, err := p.expect(tokRPAREN); err != nil {
+				return 0, err
+			}
+			return strengths[name](w), nil
+		}
+		return 0, p.errorf(namePos, "unknown strength "+name)
+	}
+	return 0, p.unexpected("strength")
+}
+
+func (p *parser) parseComparison() (node, error) {
+	x, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.isComparison() {
+		opPos, op := p.pos, p.tok
+		p.next()
+		y, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{x, opPos, op, y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseSum() (node, error) {
+	x, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == tokADD || p.tok == tokSUB {
+		opPos, op := p.pos, p.tok
+		p.next()
+		y, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{x, opPos, op, y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseProduct() (node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == tokMUL || p.tok == tokQUO {
+		opPos, op := p.pos, p.tok
+		p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{x, opPos, op, y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.tok == tokADD || p.tok == tokSUB {
+		opPos, op := p.pos, p.tok
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{opPos, op, x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	switch p.tok {
+	case tokINT, tokFLOAT:
+		x := &basicLit{p.pos, p.tok, p.lit}
+		p.next()
+		return x, nil
+	case tokIDENT:
+		x, err := p.parseName()
+		if err != nil || p.tok != tokLPAREN {
+			return x, err
+		}
+		return p.parseCall(x.(*ident))
+	case tokLPAREN:
+		lparen := p.pos
+		p.next()
+		x, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		rparen, err := p.expect(tokRPAREN)
+		if err != nil {
+			return nil, err
+		}
+		return &parenExpr{lparen, x, rparen}, nil
+	}
+	return nil, p.unexpected("operand")
+}
+
+/*
+parseName parses a dotted and indexed name like `col[3].left` into a
+single identifier holding the complete name without any whitespace.
+*/
+func (p *parser) parseName() (node, error) {
+	x := &ident{p.pos, p.lit}
+	p.next()
+	for p.tok == tokPERIOD || p.tok == tokLBRACK {
+		if p.tok == tokPERIOD {
+			p.next()
+			if p.tok != tokIDENT {
+				return nil, p.unexpected("name")
+			}
+			x.name += "." + p.lit
+			p.next()
+			continue
+		}
+		p.next()
+		if p.tok != tokINT {
+			return nil, p.unexpected("index")
+		}
+		index, err := strconv.Atoi(p.lit)
+		if err != nil {
+			return nil, p.errorf(p.pos, "invalid index "+p.lit)
+		}
+		x.name += "[" + strconv.Itoa(index) + "]"
+		p.next()
+		if _, err := p.expect(tokRBRACK); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+/*
+parseCall parses the arguments of a call to the function with the given
+name, e.g. `max(a, b)`.
+*/
+func (p *parser) parseCall(name *ident) (node, error) {
+	x := &callExpr{namePos: name.namePos, name: name.name, lparen: p.pos}
+	p.next()
+	for {
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		x.args = append(x.args, arg)
+		if p.tok != tokCOMMA {
+			break
+		}
+		p.next()
+	}
+	rparen, err := p.expect(tokRPAREN)
+	if err != nil {
+		return nil, err
+	}
+	x.rparen = rparen
+	return x, nil
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+import (
+	"sort"
+	"strings"
+)
+
+/*
+Scope is a Namespace that owns its variables.
+
+A scope maps names to variables and can hold nested scopes, e.g. one per
+component of a layout. The name `button.width` refers to the variable
+`width` in the nested scope `button`. A name that can't be resolved in a
+scope or its nested scopes is resolved in the enclosing scopes, so a
+component can refer to the variables of its parent.
+
+When created with the WithAutoCreate option, a scope creates variables
+for names that can't be resolved the first time they are referenced.
+
+Besides variables a scope holds named constants and parameters, e.g. a
+`gutter` that is used in many constraints. Constants and parameters are
+resolved like variables and take precedence over them.
+*/
+type Scope struct {
+	name       string
+	parent     *Scope
+	autoCreate bool
+	vars       map[string]*Variable
+	consts     map[string]float64
+	params     map[string]*Parameter
+	scopes     map[string]*Scope
+}
+
+var _ Namespace = &Scope{}
+var _ ValueNamespace = &Scope{}
+
+type ScopeOption func(*Scope)
+
+// WithAutoCreate is a scope option to create variables on first reference.
+func WithAutoCreate() ScopeOption {
+	return func(s *Scope) {
+		s.autoCreate = true
+	}
+}
+
+func NewScope(options ...ScopeOption) *Scope {
+	s := &Scope{
+		vars:   map[string]*Variable{},
+		consts: map[string]float64{},
+		params: map[string]*Parameter{},
+		scopes: map[string]*Scope{},
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+/*
+Path returns the dotted path of the scope from the outermost scope. The
+path of the outermost scope is empty.
+*/
+func (s *Scope) Path() string {
+	if s.parent == nil || s.parent.Path() == "" {
+		return s.name
+	}
+	return s.parent.Path() + "." + s.name
+}
+
+/*
+Scope returns the nested scope with the given name, creating it when it
+doesn't exist yet. A dotted name returns a scope nested multiple levels
+deep. Nested scopes inherit the options of their parent.
+*/
+func (s *Scope) Scope(name string) *Scope {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return s.Scope(name[:i]).Scope(name[i+1:])
+	}
+	child, present := s.scopes[name]
+	if !present {
+		child = &Scope{
+			name:       name,
+			parent:     s,
+			autoCreate: s.autoCreate,
+			vars:       map[string]*Variable{},
+			consts:     map[string]float64{},
+			params:     map[string]*Parameter{},
+			scopes:     map[string]*Scope{},
+		}
+		s.scopes[name] = child
+	}
+	return child
+}
+
+/*
+Var returns the variable with the given name in this scope, creating it
+with the optional initial value when it doesn't exist yet. The name of a
+created variable is its full path, e.g. `button.width`.
+*/
+func (s *Scope) Var(name string, value ...float64) *Variable {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return s.Scope(name[:i]).Var(name[i+1:], value...)
+	}
+	v, present := s.vars[name]
+	if !present {
+		v = Var(s.pathOf(name), value...)
+		s.vars[name] = v
+	}
+	return v
+}
+
+/*
+Add adds an existing variable to the scope under the given name,
+replacing any variable that was known under that name.
+*/
+func (s *Scope) Add(name string, variable *Variable) {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		s.Scope(name[:i]).Add(name[i+1:], variable)
+		return
+	}
+	s.vars[name] = variable
+}
+
+/*
+Const defines a named constant in this scope. The value of a constant is
+folded into the constraints that refer to it when they are evaluated, so
+redefining a constant doesn't change existing constraints. Use a
+parameter for a value that changes.
+*/
+func (s *Scope) Const(name string, value float64) {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		s.Scope(name[:i]).Const(name[i+1:], value)
+		return
+	}
+	s.consts[name] = value
+}
+
+/*
+Param returns the parameter with the given name in this scope, creating
+it with the optional initial value when it doesn't exist yet. The name
+of a created parameter is its full path, e.g. `theme.gutter`.
+*/
+func (s *Scope) Param(name string, value ...float64) *Parameter {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return s.Scope(name[:i]).Param(name[i+1:], value...)
+	}
+	p, present := s.params[name]
+	if !present {
+		initial := 0.0
+		if len(value) > 0 {
+			initial = value[0]
+		}
+		p = NewParameter(s.pathOf(name), initial)
+		s.params[name] = p
+	}
+	return p
+}
+
+func (s *Scope) pathOf(name string) string {
+	if s.Path() == "" {
+		return name
+	}
+	return s.Path() + "." + name
+}
+
+/*
+Lookup resolves the name in this scope and its nested scopes, then in the
+enclosing scopes. If the name can't be resolved and the scope was created
+with WithAutoCreate, a variable is created for the name in this scope.
+*/
+func (s *Scope) Lookup(name string) (*Variable, bool) {
+	for scope := s; scope != nil; scope = scope.parent {
+		if owner, key, present := scope.owner(name); present {
+			if v, present := owner.vars[key]; present {
+				return v, true
+			}
+		}
+	}
+	if s.autoCreate {
+		return s.Var(name), true
+	}
+	return nil, false
+}
+
+/*
+LookupConst resolves the name of a constant like Lookup resolves the name
+of a variable, but never creates anything.
+*/
+func (s *Scope) LookupConst(name string) (float64, bool) {
+	for scope := s; scope != nil; scope = scope.parent {
+		if owner, key, present := scope.owner(name); present {
+			if value, present := owner.consts[key]; present {
+				return value, true
+			}
+		}
+	}
+	return 0, false
+}
+
+/*
+LookupParam resolves the name of a parameter like Lookup resolves the
+name of a variable, but never creates anything.
+*/
+func (s *Scope) LookupParam(name string) (*Parameter, bool) {
+	for scope := s; scope != nil; scope = scope.parent {
+		if owner, key, present := scope.owner(name); present {
+			if p, present := owner.params[key]; present {
+				return p, true
+			}
+		}
+	}
+	return nil, false
+}
+
+/*
+owner returns the nested scope that would hold the last part of a dotted
+name, together with that last part.
+*/
+func (s *Scope) owner(name string) (*Scope, string, bool) {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		if child, present := s.scopes[name[:i]]; present {
+			return child.owner(name[i+1:])
+		}
+		return nil, "", false
+	}
+	return s, name, true
+}
+
+/*
+Variables returns the variables of this scope and its nested scopes
+sorted by name.
+*/
+func (s *Scope) Variables() []*Variable {
+	var vars []*Variable
+	var collect func(s *Scope)
+	collect = func(s *Scope) {
+		for _, v := range s.vars {
+			vars = append(vars, v)
+		}
+		for _, child := range s.scopes {
+			collect(child)
+		}
+	}
+	collect(s)
+	sort.Slice(vars, func(i, j int) bool {
+		return vars[i].Name < vars[j].Name
+	})
+	return vars
+}
@@ -5,6 +5,7 @@ package kiwi
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 )
 
@@ -14,6 +15,7 @@ type Solver struct {
 	vars                map[*Variable]*symbol
 	edits               map[*Variable]*edit
 	stays               map[*Variable]*Constraint
+	params              map[*Parameter]map[*Constraint]*binding
 	infeasibleRows      []*symbol
 	objective           *row
 	artificialObjective *row
@@ -26,6 +28,7 @@ func NewSolver() *Solver {
 		vars:      map[*Variable]*symbol{},
 		edits:     map[*Variable]*edit{},
 		stays:     map[*Variable]*Constraint{},
+		params:    map[*Parameter]map[*Constraint]*binding{},
 		objective: newRow(),
 	}
 }
@@ -88,6 +91,7 @@ func (s *Solver) AddConstraint(constraint *Constraint, options ...ConstraintOpti
 	}
 
 	s.cns[constraint] = tag
+	s.bind(constraint)
 
 	// Optimizing after each constraint is added performs less
 	// aggregate work due to a smaller average system size. It
@@ -110,6 +114,7 @@ func (s *Solver) RemoveConstraint(constraint *Constraint) error {
 	}
 
 	delete(s.cns, constraint)
+	s.unbind(constraint)
 
 	// Remove the error effects from the objective function
 	// *before* pivoting, or substitutions into the objective
@@ -208,16 +213,7 @@ By default when no constraint option is given the strength of the stay
 constraint will be the weakest strength possible, which is 'OPTIONAL'.
 */
 func (s *Solver) AddStay(variable *Variable, options ...ConstraintOption) error {
-	if _, present := s.stays[variable]; present {
-		return DuplicateStayVariable{variable}
-	}
-	stay := &Constraint{Expression{[]Term{{variable, 1.0}}, -variable.Value}, EQ, OPTIONAL}
-	stay.ApplyOptions(options...)
-	if err := s.AddConstraint(stay); err != nil {
-		return err
-	}
-	s.stays[variable] = stay
-	return nil
+	return s.addStay(variable, variable.Value, options...)
 }
 
 /*
@@ -232,6 +228,22 @@ func (s *Solver) RemoveStay(variable *Variable) error {
 	return s.RemoveConstraint(stay)
 }
 
+/*
+Stays returns the stay constraints of the solver in the order in which
+they were added. Every stay lists its variable, its strength and the
+value the variable is anchored to.
+*/
+func (s *Solver) Stays() []Stay {
+	stays := make([]Stay, 0, len(s.stays))
+	for v, c := range s.stays {
+		stays = append(stays, Stay{Variable: v, Strength: c.Strength, Value: -c.Expression.Constant})
+	}
+	sort.Slice(stays, func(i, j int) bool {
+		return s.cns[s.stays[stays[i].Variable]].marker.id < s.cns[s.stays[stays[j].Variable]].marker.id
+	})
+	return stays
+}
+
 /*
 HasStay tests whether a stay constraint has been added to the solver
 for the given variable.
@@ -242,20 +254,37 @@ func (s *Solver) HasStay(variable *Variable) bool {
 }
 
 /*
-uUdateStays updates all stay constraints to match the value their
+UpdateStays updates all stay constraints to match the value their
 associated variable currently holds.
 
 This is automatically called by RemoveEditVariable to commit the
 changes caused by the editing of the variable.
+
+A stay that cannot be updated to the new value is removed from the
+solver, so the stays always match the constraints in the tableau.
+
+Returns
+
+	StayErrors
+One or more stays could not be updated and have been removed.
 */
-func (s *Solver) UpdateStays() {
-	for v, c := range s.stays {
-		if !NearZero(v.Value + c.Expression.Constant) {
+func (s *Solver) UpdateStays() error {
+	var errs StayErrors
+	for _, stay := range s.Stays() {
+		v, c := stay.Variable, s.stays[stay.Variable]
+		if NearZero(v.Value + c.Expression.Constant) {
+			continue
+		}
+		if err := s.SetConstant(c, -v.Value); err != nil {
+			delete(s.stays, v)
 			s.RemoveConstraint(c)
-			c.Expression.Constant = -v.Value
-			s.AddConstraint(c)
+			errs = append(errs, StayError{v, err})
 		}
 	}
+	if errs != nil {
+		return errs
+	}
+	return nil
 }
 
 /*
@@ -296,19 +325,38 @@ func (s *Solver) AddEditVariable(variable *Variable, options ...ConstraintOption
 }
 
 /*
-RemoveEditVariable removes an edit variable from the solver.
+RemoveEditVariable removes an edit variable from the solver. The stays
+are updated to the current values of their variables first, so the
+variables stay where the edit left them.
 
 Returns
 
 	UnknownEditVariable
 The given edit variable has not been added to the solver.
+	StayErrors
+One or more stays could not be updated and have been removed. The edit
+variable is removed nonetheless.
 */
 func (s *Solver) RemoveEditVariable(variable *Variable) error {
+	if _, present := s.edits[variable]; !present {
+		return UnknownEditVariable{variable}
+	}
+	err := s.UpdateStays()
+	if rerr := s.removeEditVariable(variable); rerr != nil {
+		return rerr
+	}
+	return err
+}
+
+/*
+removeEditVariable removes an edit variable from the solver without
+updating the stay constraints.
+*/
+func (s *Solver) removeEditVariable(variable *Variable) error {
 	edit, present := s.edits[variable]
 	if !present {
 		return UnknownEditVariable{variable}
 	}
-	s.UpdateStays()
 	delete(s.edits, variable)
 	return s.RemoveConstraint(edit.constraint)
 }
@@ -341,28 +389,200 @@ func (s *Solver) SuggestValue(variable *Variable, value float64) error {
 	}
 	delta := value - info.constant
 	info.constant = value
+	s.shiftMarker(info.tag, delta)
+	return s.dualOptimize()
+}
+
+/*
+SuggestValues suggests values for a number of edit variables at once.
 
-	if row, present := s.rows[info.tag.marker]; present {
+This method behaves like calling `SuggestValue` for every variable in the
+map, except that the system is dual optimized only once after all the
+suggestions have been applied. This is considerably faster when many
+edit variables change together, e.g. when dragging a point.
+
+Returns
+
+	UnknownEditVariable
+One of the given edit variables has not been added to the solver. No
+suggestions are applied in that case.
+*/
+func (s *Solver) SuggestValues(values map[*Variable]float64) error {
+	for variable := range values {
+		if _, present := s.edits[variable]; !present {
+			return UnknownEditVariable{variable}
+		}
+	}
+	for variable, value := range values {
+		info := s.edits[variable]
+		delta := value - info.constant
+		info.constant = value
+		s.shiftMarker(info.tag, delta)
+	}
+	return s.dualOptimize()
+}
+
+/*
+SetConstant changes the constant of a constraint that has already been
+added to the solver, without removing and re-adding the constraint.
+
+The constraint is of the form `expression op 0`, so the value given is
+the new constant of the constraint's expression. E.g. for a constraint
+created with `x.EqualsConstant(12)` the constant is -12. The tableau is
+updated in place through the marker symbol of the constraint after
+which the system is re-optimized using the dual simplex method.
+
+Returns
+
+	UnknownConstraint
+The given constraint has not been added to the solver.
+	UnsatisfiableConstraint
+The given constraint is required and cannot be satisfied with the new
+constant. The constraint keeps its previous constant in that case.
+*/
+func (s *Solver) SetConstant(constraint *Constraint, value float64) error {
+	tag, present := s.cns[constraint]
+	if !present {
+		return UnknownConstraint{constraint}
+	}
+	delta := value - constraint.Expression.Constant
+	if NearZero(delta) {
+		constraint.Expression.Constant = value
+		return nil
+	}
+
+	// A basic dummy marker means the constraint was redundant when it was
+	// added. Its row can't absorb a change of constant.
+	if _, present := s.rows[tag.marker]; present && tag.marker.is(DUMMY) {
+		return UnsatisfiableConstraint{constraint}
+	}
+	previous := constraint.Expression.Constant
+	constraint.Expression.Constant = value
+
+	// The marker enters the row created for the constraint with a
+	// coefficient of +1 for a slack of a LE or a dummy of a required
+	// EQ and with -1 for a slack of a GE or the error of a non-required
+	// EQ. Changing the constant then amounts to shifting the marker.
+	if constraint.Operator == GE || tag.marker.is(ERROR) {
+		delta = -delta
+	}
+	s.shiftMarker(tag, delta)
+	if err := s.dualOptimize(); err != nil {
+		// Shift the marker back and restore feasibility, so the solver
+		// is left in the state it was in before the call.
+		constraint.Expression.Constant = previous
+		s.shiftMarker(tag, -delta)
+		s.infeasibleRows = s.infeasibleRows[:0]
+		for sym, row := range s.rows {
+			if !sym.is(EXTERNAL) && row.constant < 0.0 {
+				s.infeasibleRows = append(s.infeasibleRows, sym)
+			}
+		}
+		s.dualOptimize()
+		return UnsatisfiableConstraint{constraint}
+	}
+	return nil
+}
+
+/*
+shiftMarker updates the constants of the rows in the tableau to reflect
+a shift of delta in the value of the marker symbol of the given tag.
+
+Rows that become infeasible are added to the infeasible rows, so this
+method should be followed by a call to dualOptimize.
+*/
+func (s *Solver) shiftMarker(tag tag, delta float64) {
+	if row, present := s.rows[tag.marker]; present {
 		// Check first if the positive error variable is basic.
 		if row.add(-delta) < 0.0 {
-			s.infeasibleRows = append(s.infeasibleRows, info.tag.marker)
+			s.infeasibleRows = append(s.infeasibleRows, tag.marker)
 		}
-	} else if row, present = s.rows[info.tag.other]; present {
+	} else if row, present = s.rows[tag.other]; present {
 		// Check next if the negative error variable is basic.
 		if row.add(delta) < 0.0 {
-			s.infeasibleRows = append(s.infeasibleRows, info.tag.other)
+			s.infeasibleRows = append(s.infeasibleRows, tag.other)
 		}
 	} else {
 		// Otherwise update each row where the error variables exist.
 		for sym, row := range s.rows {
-			coeff := row.coefficientFor(info.tag.marker)
+			coeff := row.coefficientFor(tag.marker)
 			if coeff != 0.0 && row.add(delta*coeff) < 0.0 && !sym.is(EXTERNAL) {
 				s.infeasibleRows = append(s.infeasibleRows, sym)
 			}
 		}
 	}
+}
 
-	return s.dualOptimize()
+/*
+Constraints returns the constraints that have been added to the solver in
+the order in which they were added.
+
+The constraints created by the solver for edit variables and stays are
+not included, see EditVariables and Stays.
+*/
+func (s *Solver) Constraints() []*Constraint {
+	owned := make(map[*Constraint]bool, len(s.edits)+len(s.stays))
+	for _, e := range s.edits {
+		owned[e.constraint] = true
+	}
+	for _, c := range s.stays {
+		owned[c] = true
+	}
+	constraints := make([]*Constraint, 0, len(s.cns)-len(owned))
+	for c := range s.cns {
+		if !owned[c] {
+			constraints = append(constraints, c)
+		}
+	}
+	sort.Slice(constraints, func(i, j int) bool {
+		return s.cns[constraints[i]].marker.id < s.cns[constraints[j]].marker.id
+	})
+	return constraints
+}
+
+/*
+EditVariables returns the edit variables of the solver in the order in
+which they were added. Every edit variable lists its variable, its
+strength and the value last suggested for it.
+*/
+func (s *Solver) EditVariables() []EditVariable {
+	edits := make([]EditVariable, 0, len(s.edits))
+	for v, e := range s.edits {
+		edits = append(edits, EditVariable{Variable: v, Strength: e.constraint.Strength, Value: e.constant})
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return s.edits[edits[i].Variable].tag.marker.id < s.edits[edits[j].Variable].tag.marker.id
+	})
+	return edits
+}
+
+/*
+Variables returns the variables known to the solver in the order in which
+they first appeared in a constraint.
+*/
+func (s *Solver) Variables() []*Variable {
+	vars := make([]*Variable, 0, len(s.vars))
+	for v := range s.vars {
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool {
+		return s.vars[vars[i]].id < s.vars[vars[j]].id
+	})
+	return vars
+}
+
+/*
+IsBasic tests whether the given variable is basic in the tableau, i.e.
+whether its value is determined by a row of the tableau. Variables that
+are unknown to the solver are not basic.
+*/
+func (s *Solver) IsBasic(variable *Variable) bool {
+	sym, present := s.vars[variable]
+	if !present {
+		return false
+	}
+	_, present = s.rows[sym]
+	return present
 }
 
 /*
@@ -403,6 +623,9 @@ func (s *Solver) Reset() {
 	for k := range s.stays {
 		delete(s.stays, k)
 	}
+	for k := range s.params {
+		delete(s.params, k)
+	}
 	s.infeasibleRows = nil
 	s.objective = newRow()
 	s.artificialObjective = nil
@@ -653,20 +876,20 @@ func (s Solver) String() string {
 	fmt.Fprintln(&sb)
 	fmt.Fprintln(&sb, "Variables")
 	fmt.Fprintln(&sb, "---------")
-	for v, s := range s.vars {
-		fmt.Fprintln(&sb, v, " = ", s)
+	for _, v := range s.Variables() {
+		fmt.Fprintln(&sb, v, " = ", s.vars[v])
 	}
 	fmt.Fprintln(&sb)
 	fmt.Fprintln(&sb, "Edit Variables")
 	fmt.Fprintln(&sb, "--------------")
-	for e := range s.edits {
-		fmt.Fprintln(&sb, e)
+	for _, e := range s.EditVariables() {
+		fmt.Fprintln(&sb, e.Variable)
 	}
 	fmt.Fprintln(&sb)
 	fmt.Fprintln(&sb, "Stay Constraints")
 	fmt.Fprintln(&sb, "----------------")
-	for v, c := range s.stays {
-		fmt.Fprintln(&sb, v, " == ", -c.Expression.Constant)
+	for _, stay := range s.Stays() {
+		fmt.Fprintln(&sb, stay.Variable, " == ", stay.Value)
 	}
 	fmt.Fprintln(&sb)
 	fmt.Fprintln(&sb, "Constraints")
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package kiwi
+
+// Stay describes a stay constraint that anchors a variable to a value.
+type Stay struct {
+	Variable *Variable
+	Strength Strength
+	Value    float64
+}
+
+/*
+addStay adds a stay for the variable anchored to the given value instead
+of the value the variable currently holds.
+*/
+func (s *Solver) addStay(variable *Variable, value float64, options ...ConstraintOption) error {
+	if _, present := s.stays[variable]; present {
+		return DuplicateStayVariable{variable}
+	}
+	stay := &Constraint{Expression: Expression{[]Term{{variable, 1.0}}, -value}, Operator: EQ, Strength: OPTIONAL}
+	stay.ApplyOptions(options...)
+	if err := s.AddConstraint(stay); err != nil {
+		// Don't leave a stay in the tableau that is not listed in stays.
+		if s.HasConstraint(stay) {
+			s.RemoveConstraint(stay)
+		}
+		return err
+	}
+	s.stays[variable] = stay
+	return nil
+}
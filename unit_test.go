@@ -3,6 +3,8 @@
 package kiwi
 
 import (
+	"encoding/json"
+	"errors"
 	"math"
 	"strings"
 	"testing"
@@ -45,6 +47,867 @@ func TestParser(t *testing.T) {
 	assert.EqualString(t, "5.75 * xr + 1.5 * xl + -1 * xm + 0 == 0 | Strength = Strong(123)", cns.String(), "cns.String()")
 }
 
+func TestConstraintParser(t *testing.T) {
+	// Dotted names are parsed as a single variable name
+	expr, err := ParseExpr("C0.X + 20 <= C2.X")
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "C0.X + INT(20) <= C2.X", expr.String(), "ast.String()")
+
+	c0x, c2x := Var("C0.X"), Var("C2.X")
+	cns, err := expr.NewConstraint([]*Variable{c0x, c2x})
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "C0.X + -1 * C2.X + 20 <= 0 | Strength = REQUIRED", cns.String(), "cns.String()")
+
+	// Numbers in various forms
+	expr, err = ParseExpr("x == 1.5e2 + .25 - 3.")
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "x == FLOAT(1.5e2) + FLOAT(.25) - FLOAT(3.)", expr.String(), "ast.String()")
+
+	// Chained comparisons yield one constraint per operator
+	x := Var("x")
+	expr, err = ParseExpr("0 <= x <= 100")
+	assert.Equal(t, nil, err, "err")
+	cnss, err := expr.NewConstraints([]*Variable{x})
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, 2, len(cnss), "len(cnss)")
+	assert.EqualString(t, "x + -0 >= 0 | Strength = REQUIRED", cnss[0].String(), "cnss[0].String()")
+	assert.EqualString(t, "x + -100 <= 0 | Strength = REQUIRED", cnss[1].String(), "cnss[1].String()")
+	_, err = expr.NewConstraint([]*Variable{x})
+	assert.NotEqual(t, nil, err, "err")
+
+	// Syntax errors carry the offset of the offending token
+	for src, offset := range map[string]int{"x + * 2": 4, "x < 10": 2, "(x + 2": 6, "x y": 2, "a. == 2": 3} {
+		_, err = ParseExpr(src)
+		perr, ok := err.(ParseError)
+		assert.Equal(t, true, ok, "%q: _, ok := err.(ParseError); ok", src)
+		assert.Equal(t, offset, perr.Offset, "%q: perr.Offset", src)
+		assert.Equal(t, true, errors.Is(err, SyntaxError), "%q: errors.Is(err, SyntaxError)", src)
+	}
+
+	// Unknown names are reported
+	_, err = ParseConstraint("button.width >= 10", []*Variable{x})
+	var unknown UnknownVariableName
+	assert.Equal(t, true, errors.As(err, &unknown), "errors.As(err, &unknown)")
+	assert.EqualString(t, "button.width", unknown.Name, "unknown.Name")
+}
+
+func TestNamespace(t *testing.T) {
+	ns := Names{}
+	for _, name := range []string{"C0.X", "C2.X", "col[3].left", "col[4].left", "grid.col[3].width"} {
+		ns[name] = Var(name)
+	}
+
+	cns, err := ParseConstraintIn("C0.X + 20 <= C2.X", ns)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "C0.X + -1 * C2.X + 20 <= 0 | Strength = REQUIRED", cns.String(), "cns.String()")
+
+	cns, err = ParseConstraintIn("col[ 03 ] . left + grid.col[3].width == col[4].left", ns)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "col[3].left + grid.col[3].width + -1 * col[4].left + 0 == 0 | Strength = REQUIRED", cns.String(), "cns.String()")
+
+	_, err = ParseConstraintIn("col[5].left >= 0", ns)
+	var unknown UnknownVariableName
+	assert.Equal(t, true, errors.As(err, &unknown), "errors.As(err, &unknown)")
+	assert.EqualString(t, "col[5].left", unknown.Name, "unknown.Name")
+
+	for _, src := range []string{"col[x].left >= 0", "col[-1].left >= 0", "col[3.5] >= 0", "col[3 >= 0", "col.[3] >= 0"} {
+		_, err = ParseExpr(src)
+		assert.Equal(t, true, errors.Is(err, SyntaxError), "%q: errors.Is(err, SyntaxError)", src)
+	}
+}
+
+func TestParseConstraints(t *testing.T) {
+	ns := Names{}
+	for _, name := range []string{"header.bottom", "body.top", "body.left"} {
+		ns[name] = Var(name)
+	}
+
+	doc := `# layout
+header_gap: header.bottom + 8 == body.top // gap between header and body
+
+0 <= body.left <= 20 | strong
+body.top >= 0
+`
+	cnss, err := ParseConstraints(strings.NewReader(doc), ns)
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, 4, len(cnss), "len(cnss)")
+	assert.EqualString(t, "header_gap", cnss[0].Label, "cnss[0].Label")
+	assert.Equal(t, 2, cnss[0].Line, "cnss[0].Line")
+	assert.EqualString(t, "header.bottom + -1 * body.top + 8 == 0 | Strength = REQUIRED", cnss[0].String(), "cnss[0].String()")
+	assert.Equal(t, 4, cnss[1].Line, "cnss[1].Line")
+	assert.Equal(t, 4, cnss[2].Line, "cnss[2].Line")
+	assert.Equal(t, STRONG, cnss[2].Strength, "cnss[2].Strength")
+	assert.EqualString(t, "", cnss[3].Label, "cnss[3].Label")
+	assert.Equal(t, 5, cnss[3].Line, "cnss[3].Line")
+
+	doc = `a: body.top >= 0
+body.top >= header.bottom +
+a: body.top <= 100
+ok: body.left >= 10
+footer.top >= body.top
+`
+	cnss, err = ParseConstraints(strings.NewReader(doc), ns)
+	assert.Equal(t, 0, len(cnss), "len(cnss)")
+	errs, ok := err.(ParseErrors)
+	assert.Equal(t, true, ok, "_, ok := err.(ParseErrors); ok")
+	assert.Equal(t, 3, len(errs), "len(errs)")
+	if len(errs) == 3 {
+		assert.Equal(t, 2, errs[0].Line, "errs[0].Line")
+		assert.Equal(t, true, errors.Is(errs[0], SyntaxError), "errors.Is(errs[0], SyntaxError)")
+		assert.Equal(t, 3, errs[1].Line, "errs[1].Line")
+		assert.Equal(t, DuplicateLabel{"a", 1}, errs[1].Err, "errs[1].Err")
+		assert.Equal(t, 5, errs[2].Line, "errs[2].Line")
+		assert.Equal(t, UnknownVariableName{"footer.top"}, errs[2].Err, "errs[2].Err")
+	}
+}
+
+func TestScope(t *testing.T) {
+	root := NewScope()
+	gutter := root.Var("gutter", 16)
+	button := root.Scope("button")
+	width := button.Var("width")
+	assert.EqualString(t, "button.width", width.Name, "width.Name")
+	assert.Equal(t, width, root.Var("button.width"), "root.Var(\"button.width\")")
+
+	// Names resolve in nested scopes and fall back to enclosing scopes
+	cns, err := ParseConstraintIn("width >= 2 * gutter", button)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "button.width + -2 * gutter + 0 >= 0 | Strength = REQUIRED", cns.String(), "cns.String()")
+	_, err = ParseConstraintIn("button.width >= gutter", root)
+	assert.Equal(t, nil, err, "err")
+	_, err = ParseConstraintIn("button.height >= gutter", root)
+	assert.Equal(t, UnknownVariableName{"button.height"}, errors.Unwrap(err), "errors.Unwrap(err)")
+	v, ok := button.Lookup("gutter")
+	assert.Equal(t, true, ok, "ok")
+	assert.Equal(t, gutter, v, "button.Lookup(\"gutter\")")
+
+	// Variables are created on first reference and reused afterwards
+	auto := NewScope(WithAutoCreate())
+	doc := `label.left + label.width <= panel.width
+label.width >= 10
+col[2].left == panel.left + 8`
+	cnss, err := ParseConstraints(strings.NewReader(doc), auto)
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, 3, len(cnss), "len(cnss)")
+	assert.Equal(t, cnss[0].Expression.Terms[1].Variable, cnss[1].Expression.Terms[0].Variable, "label.width")
+
+	var names []string
+	for _, v := range auto.Variables() {
+		names = append(names, v.Name)
+	}
+	assert.EqualString(t, "col[2].left label.left label.width panel.left panel.width", strings.Join(names, " "), "names")
+
+	nested := auto.Scope("panel").Scope("inner")
+	v, ok = nested.Lookup("label.width")
+	assert.Equal(t, true, ok, "ok")
+	assert.Equal(t, cnss[1].Expression.Terms[0].Variable, v, "nested.Lookup(\"label.width\")")
+	v, _ = nested.Lookup("depth")
+	assert.EqualString(t, "panel.inner.depth", v.Name, "v.Name")
+}
+
+func TestParameters(t *testing.T) {
+	root := NewScope(WithAutoCreate())
+	root.Const("unit", 4)
+	gutter := root.Param("theme.gutter", 16)
+	assert.EqualString(t, "theme.gutter = 16", gutter.String(), "gutter.String()")
+	assert.Equal(t, gutter, root.Param("theme.gutter"), "root.Param(\"theme.gutter\")")
+
+	// Constants fold into the constraint, even in products
+	cns, err := ParseConstraintIn("a.width == 10 * unit", root)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "a.width + -40 == 0 | Strength = REQUIRED", cns.String(), "cns.String()")
+
+	doc := `a.left == 0
+a.width == 100
+a.right == a.left + a.width
+a.right + theme.gutter <= b.left
+b.left == a.right + 2 * theme.gutter | weak`
+	cnss, err := ParseConstraints(strings.NewReader(doc), root)
+	assert.Equal(t, nil, err, "err")
+	s := NewSolver()
+	for _, c := range cnss {
+		err := s.AddConstraint(c.Constraint)
+		assert.Equal(t, nil, err, "%v: err", c)
+	}
+	s.UpdateVariables()
+	left := root.Var("b.left")
+	assert.EqualFloat64(t, 132, left.Value, "b.left.Value")
+	_, present := root.LookupConst("theme.gutter")
+	assert.Equal(t, false, present, "theme.gutter is not a constant")
+	for _, v := range root.Variables() {
+		assert.NotEqual(t, "theme.gutter", v.Name, "theme.gutter is not a variable")
+	}
+
+	// Changing the parameter updates the constraints in place
+	err = s.SetParameter(gutter, 8)
+	assert.Equal(t, nil, err, "err")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 8, gutter.Value(), "gutter.Value()")
+	assert.EqualFloat64(t, 116, left.Value, "b.left.Value")
+	assert.EqualString(t, "a.right + -1 * b.left + 8 <= 0 | Strength = REQUIRED", cnss[3].String(), "cnss[3].String()")
+
+	// A parameter can be set in a nested scope through the enclosing scope
+	p, ok := root.Scope("b").LookupParam("theme.gutter")
+	assert.Equal(t, true, ok, "ok")
+	assert.Equal(t, gutter, p, "LookupParam")
+
+	// An unsatisfiable value leaves the constraint as it was
+	limit, err := ParseConstraintIn("b.left <= 150 - theme.gutter", root)
+	assert.Equal(t, nil, err, "err")
+	s.AddConstraint(limit)
+	err = s.SetParameter(gutter, 30)
+	assert.Equal(t, UnsatisfiableConstraint{limit}, err, "err")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 142, left.Value, "b.left.Value")
+	err = s.SetParameter(gutter, 10)
+	assert.Equal(t, nil, err, "err")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 120, left.Value, "b.left.Value")
+	assert.EqualString(t, "b.left + -140 <= 0 | Strength = REQUIRED", limit.String(), "limit.String()")
+
+	// Only the constraints in the solver are updated and remembered
+	assert.Equal(t, nil, s.RemoveConstraint(limit), "s.RemoveConstraint(limit)")
+	assert.Equal(t, 2, len(s.params[gutter]), "len(s.params[gutter])")
+	err = s.SetParameter(gutter, 12)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "b.left + -140 <= 0 | Strength = REQUIRED", limit.String(), "limit.String()")
+	assert.Equal(t, nil, s.AddConstraint(limit), "s.AddConstraint(limit)")
+	assert.Equal(t, 3, len(s.params[gutter]), "len(s.params[gutter])")
+	err = s.SetParameter(gutter, 14)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "b.left + -136 <= 0 | Strength = REQUIRED", limit.String(), "limit.String()")
+	for _, c := range []*Constraint{cnss[3].Constraint, cnss[4].Constraint, limit} {
+		s.RemoveConstraint(c)
+	}
+	assert.Equal(t, 0, len(s.params), "len(s.params)")
+
+	// Parameters must appear linearly
+	_, err = ParseConstraintIn("a.width * theme.gutter >= 0", root)
+	assert.NotEqual(t, nil, err, "err")
+}
+
+func TestFormatConstraint(t *testing.T) {
+	xl, xm, xr, x, y := Var("xl"), Var("xm"), Var("xr"), Var("x"), Var("y")
+	vars := []*Variable{xl, xm, xr, x, y}
+
+	c := NewConstraint(Expression{[]Term{{xr, 0.125}, {xl, 0.125}, {xm, -1}}, 7}, EQ, WithStrength(Strong(123)))
+	assert.EqualString(t, "xm == 0.125*xr + 0.125*xl + 7 | strong(123)", FormatConstraint(c), "FormatConstraint(c)")
+	assert.EqualString(t, "0.125*xr == -0.125*xl + xm - 7 | strong(123)", FormatConstraint(c, WithLeft(xr)), "WithLeft(xr)")
+	assert.EqualString(t, "0.125*xr + 0.125*xl - xm == -7 | strong(123)", FormatConstraint(c, WithTermsLeft()), "WithTermsLeft()")
+	assert.EqualString(t, "x <= y - 5", FormatConstraint(x.AddConstant(5).LessThanOrEqualsVariable(y)), "x + 5 <= y")
+	assert.EqualString(t, "x >= 0 | required", FormatConstraint(x.GreaterThanOrEqualsConstant(0), WithRequired()), "WithRequired()")
+
+	// The left side leaves the fewest negated terms on the right, whatever
+	// the order of the terms.
+	assert.EqualString(t, "y == xl + xr", FormatConstraint(xl.AddVariable(xr).EqualsVariable(y)), "xl + xr == y")
+	assert.EqualString(t, "y == xr + xl", FormatConstraint(y.Negate().AddVariable(xr).AddVariable(xl).EqualsConstant(0)), "-y + xr + xl == 0")
+
+	// The formatted text parses back into an equivalent constraint and
+	// formats to the same text again.
+	equivalent := func(a, b *Constraint) bool {
+		same := func(scale float64) bool {
+			coefficients := map[*Variable]float64{}
+			for _, t := range a.Expression.Terms {
+				coefficients[t.Variable] += t.Coefficient
+			}
+			for _, t := range b.Expression.Terms {
+				coefficients[t.Variable] -= scale * t.Coefficient
+			}
+			for _, coefficient := range coefficients {
+				if !NearZero(coefficient) {
+					return false
+				}
+			}
+			return NearZero(a.Expression.Constant - scale*b.Expression.Constant)
+		}
+		if a.Strength != b.Strength {
+			return false
+		}
+		switch {
+		case a.Operator == EQ && b.Operator == EQ:
+			return same(1) || same(-1)
+		case a.Operator == b.Operator:
+			return same(1)
+		case a.Operator != EQ && b.Operator != EQ:
+			return same(-1)
+		}
+		return false
+	}
+	strength := func(c *Constraint, strength Strength) *Constraint {
+		c.ApplyOptions(WithStrength(strength))
+		return c
+	}
+	cnss := []*Constraint{
+		c,
+		x.EqualsConstant(12),
+		x.EqualsConstant(-12),
+		x.Negate().AddConstant(10).GreaterThanOrEqualsConstant(0),
+		x.Multiply(2).AddTerm(y.Multiply(3)).LessThanOrEqualsConstant(10),
+		x.Multiply(-2).AddTerm(y.Multiply(0.5)).GreaterThanOrEqualsConstant(1.5),
+		strength(x.AddVariable(y).EqualsVariable(xm), WEAK),
+		strength(x.LessThanOrEqualsVariable(y), Medium(250)),
+		strength(y.GreaterThanOrEqualsConstant(0), OPTIONAL),
+		strength(y.EqualsConstant(1e-7), Strength(12345.5)),
+	}
+	for _, c := range cnss {
+		for _, options := range [][]FormatOption{nil, {WithLeft(y)}, {WithTermsLeft()}, {WithRequired()}} {
+			text := FormatConstraint(c, options...)
+			parsed, err := ParseConstraint(text, vars)
+			assert.Equal(t, nil, err, "%q: err", text)
+			if err != nil {
+				continue
+			}
+			assert.Equal(t, true, equivalent(c, parsed), "%q parses to %v, want %v", text, parsed, c)
+			if options == nil {
+				assert.EqualString(t, text, FormatConstraint(parsed), "FormatConstraint(%v)", parsed)
+			}
+		}
+	}
+}
+
+func TestSolverFile(t *testing.T) {
+	left, width, right := Var("box.left", 10), Var("box.width", 100), Var("box.right")
+	s := NewSolver()
+	s.AddConstraint(right.EqualsExpression(left.AddVariable(width)))
+	s.AddConstraint(width.GreaterThanOrEqualsConstant(20))
+	s.AddConstraint(right.LessThanOrEqualsConstant(500), WithStrength(Strong(2)))
+	s.AddStay(left, WithStrength(Weak(2)))
+	s.AddStay(width, WithStrength(WEAK))
+	s.AddEditVariable(right, WithStrength(STRONG))
+	s.SuggestValue(right, 300)
+	s.UpdateVariables()
+
+	var b strings.Builder
+	n, err := s.WriteTo(&b)
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, int64(b.Len()), n, "n")
+	file := `var box.left = 10
+var box.right = 300
+var box.width = 290
+
+box.right == box.left + box.width
+box.width >= 20
+box.right <= 500 | strong(2)
+
+stay box.left = 10 | weak(2)
+stay box.width = 100 | weak
+
+edit box.right = 300 | strong
+`
+	assert.EqualString(t, file, b.String(), "s.WriteTo(&b)")
+
+	// Loading and writing the solver again results in the same file
+	loaded, scope, err := LoadSolver(strings.NewReader(b.String()))
+	assert.Equal(t, nil, err, "err")
+	b.Reset()
+	loaded.WriteTo(&b)
+	assert.EqualString(t, file, b.String(), "loaded.WriteTo(&b)")
+	loaded.UpdateVariables()
+	v, _ := scope.Lookup("box.width")
+	assert.EqualFloat64(t, 290, v.Value, "box.width")
+	loaded.SuggestValue(scope.Var("box.right"), 50)
+	loaded.UpdateVariables()
+	assert.EqualFloat64(t, 40, v.Value, "box.width")
+
+	// Undeclared variables, labels and comments
+	loaded, scope, err = LoadSolver(strings.NewReader(`# layout
+gap: x + 8 == y
+var x = -4
+stay x`))
+	assert.Equal(t, nil, err, "err")
+	loaded.UpdateVariables()
+	assert.EqualFloat64(t, 4, scope.Var("y").Value, "y")
+	assert.Equal(t, OPTIONAL, loaded.Stays()[0].Strength, "stay strength")
+
+	// A required stay is anchored to its value, not to the current value
+	loaded, scope, err = LoadSolver(strings.NewReader("x >= 3\nstay x = 5 | required"))
+	assert.Equal(t, nil, err, "err")
+	loaded.UpdateVariables()
+	assert.EqualFloat64(t, 5, scope.Var("x").Value, "x")
+	_, _, err = LoadSolver(strings.NewReader("x >= 3\nstay x = 2 | required"))
+	assert.NotEqual(t, nil, err, "err")
+
+	_, _, err = LoadSolver(strings.NewReader(`var x = 1 | weak
+stay x
+stay x
+edit x | required
+x == 1
+x == 2`))
+	var errs ParseErrors
+	assert.Equal(t, true, errors.As(err, &errs), "errors.As(err, &errs)")
+	assert.Equal(t, 4, len(errs), "len(errs)")
+	assert.Equal(t, 1, errs[0].Line, "errs[0].Line")
+	assert.Equal(t, true, errors.Is(errs[0], SyntaxError), "errors.Is(errs[0], SyntaxError)")
+	assert.Equal(t, 3, errs[1].Line, "errs[1].Line")
+	assert.Equal(t, true, errors.As(errs[1], &DuplicateStayVariable{}), "DuplicateStayVariable")
+	assert.Equal(t, BadRequiredStrength, errs[2].Err, "errs[2].Err")
+	assert.Equal(t, 6, errs[3].Line, "errs[3].Line")
+
+	aux, group := Max(left.AddConstant(0), width.AddConstant(0))
+	s.AddConstraint(right.GreaterThanOrEqualsVariable(aux))
+	for _, c := range group {
+		s.AddConstraint(c)
+	}
+	_, err = s.WriteTo(&b)
+	assert.Equal(t, InvalidVariableName{aux}, err, "err")
+
+	// Variables that share a name can't be read back as different variables
+	w1, w2 := Var("w"), Var("w")
+	s = NewSolver()
+	s.AddConstraint(w1.EqualsConstant(10))
+	s.AddConstraint(w2.EqualsConstant(20))
+	b.Reset()
+	n, err = s.WriteTo(&b)
+	assert.Equal(t, DuplicateVariableName{w2}, err, "err")
+	assert.Equal(t, int64(0), n, "n")
+	assert.EqualString(t, "", b.String(), "s.WriteTo(&b)")
+	w2.Name = "w2"
+	b.Reset()
+	s.WriteTo(&b)
+	loaded, scope, err = LoadSolver(strings.NewReader(b.String()))
+	assert.Equal(t, nil, err, "err")
+	loaded.UpdateVariables()
+	assert.EqualFloat64(t, 10, scope.Var("w").Value, "w")
+	assert.EqualFloat64(t, 20, scope.Var("w2").Value, "w2")
+}
+
+func TestJSON(t *testing.T) {
+	for _, strength := range []Strength{REQUIRED, STRONG, Strong(123), Medium(2.5), WEAK, OPTIONAL, Strength(12345.5)} {
+		data, err := json.Marshal(strength)
+		assert.Equal(t, nil, err, "err")
+		var decoded Strength
+		err = json.Unmarshal(data, &decoded)
+		assert.Equal(t, nil, err, "err")
+		assert.Equal(t, strength, decoded, "json.Unmarshal(%s)", data)
+	}
+	var strength Strength
+	json.Unmarshal([]byte(`"Medium(250)"`), &strength)
+	assert.Equal(t, Medium(250), strength, "Medium(250)")
+	json.Unmarshal([]byte(`1000`), &strength)
+	assert.Equal(t, MEDIUM, strength, "1000")
+	assert.NotEqual(t, nil, json.Unmarshal([]byte(`"very strong"`), &strength), "very strong")
+
+	x, y := Var("x", 3), Var("y")
+	c := x.Multiply(2).AddTerm(y.Negate()).AddConstant(10).LessThanOrEqualsConstant(0)
+	c.ApplyOptions(WithStrength(Strong(123)))
+	data, err := json.Marshal(c)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, `{"expression":{"terms":[{"variable":"x","coefficient":2},{"variable":"y","coefficient":-1}],"constant":10},"operator":"\u003c=","strength":"strong(123)"}`, string(data), "json.Marshal(c)")
+	data, _ = json.Marshal(x)
+	assert.EqualString(t, `{"name":"x","value":3}`, string(data), "json.Marshal(x)")
+
+	// Terms with the same name share a variable, strength defaults to required
+	var decoded Constraint
+	err = json.Unmarshal([]byte(`{"expression":{"terms":[{"variable":"a","coefficient":1},{"variable":"b","coefficient":1},{"variable":"a","coefficient":2}],"constant":-1},"operator":">="}`), &decoded)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "3 * a + b + -1 >= 0 | Strength = REQUIRED", decoded.String(), "decoded.String()")
+	assert.NotEqual(t, nil, json.Unmarshal([]byte(`{"operator":"<"}`), &decoded), "invalid operator")
+
+	// A solver spec rebuilds an equivalent solver
+	left, width, right := Var("left", 10), Var("width", 100), Var("right")
+	other := Var("width")
+	s := NewSolver()
+	s.AddConstraint(right.EqualsExpression(left.AddVariable(width)))
+	s.AddConstraint(other.EqualsVariable(width))
+	s.AddStay(left, WithStrength(Weak(2)))
+	s.AddStay(width, WithStrength(WEAK))
+	s.AddEditVariable(right, WithStrength(STRONG))
+	s.SuggestValue(right, 300)
+	s.UpdateVariables()
+
+	data, err = json.Marshal(s.Spec())
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, true, strings.Contains(string(data), `{"id":"width#2","name":"width","value":290}`), "id of width: %s", data)
+	var spec SolverSpec
+	err = json.Unmarshal(data, &spec)
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, 4, len(spec.Variables), "len(spec.Variables)")
+	loaded, err := spec.NewSolver()
+	assert.Equal(t, nil, err, "err")
+	again, _ := json.Marshal(loaded.Spec())
+	assert.EqualString(t, string(data), string(again), "json.Marshal(loaded.Spec())")
+
+	loaded.SuggestValue(spec.Edits[0].Variable, 50)
+	loaded.UpdateVariables()
+	assert.EqualString(t, "left", spec.Stays[0].Variable.Name, "spec.Stays[0].Variable.Name")
+	assert.EqualFloat64(t, 10, spec.Stays[0].Variable.Value, "left")
+	for _, v := range spec.Variables {
+		if v.Name == "width" {
+			assert.EqualFloat64(t, 40, v.Value, "width")
+		}
+	}
+
+	err = json.Unmarshal([]byte(`{"variables":[{"name":"x"}],"constraints":[{"expression":{"terms":[{"variable":"z","coefficient":1}]},"operator":"=="}]}`), &spec)
+	assert.Equal(t, UnknownVariableName{"z"}, err, "err")
+
+	err = json.Unmarshal([]byte(`{"variables":[{"name":"x"}],"constraints":[{"expression":{"terms":[{"variable":"x","coefficient":1}],"constant":-3},"operator":">="}],"stays":[{"variable":"x","value":5,"strength":"required"}]}`), &spec)
+	assert.Equal(t, nil, err, "err")
+	loaded, err = spec.NewSolver()
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, 1, len(loaded.Stays()), "len(loaded.Stays())")
+	loaded.UpdateVariables()
+	assert.EqualFloat64(t, 5, spec.Variables[0].Value, "x")
+}
+
+func TestWriteLP(t *testing.T) {
+	left, right, col := Var("box.left", 10), Var("box.right"), Var("col[2]")
+	s := NewSolver()
+	s.AddConstraint(right.GreaterThanOrEqualsExpression(left.AddConstant(20)))
+	s.AddConstraint(right.LessThanOrEqualsConstant(500), WithStrength(Strong(2)))
+	s.AddConstraint(col.EqualsVariable(left))
+	s.AddStay(left, WithStrength(WEAK))
+	s.AddEditVariable(right, WithStrength(STRONG))
+	s.SuggestValue(right, 300)
+
+	var b strings.Builder
+	err := s.WriteLP(&b)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, `\ kiwi solver
+\ col_2_ is col[2]
+Minimize
+ obj: + 2000000 c2_err + stay1_plus + stay1_minus + 1000000 edit1_plus + 1000000 edit1_minus
+Subject To
+ c1: + box.right - box.left >= 20
+ c2: + box.right - c2_err <= 500
+ c3: + col_2_ - box.left = 0
+ stay1: + box.left - stay1_plus + stay1_minus = 10
+ edit1: + box.right - edit1_plus + edit1_minus = 300
+Bounds
+ box.right free
+ box.left free
+ col_2_ free
+End
+`, b.String(), "s.WriteLP(&b)")
+
+	b.Reset()
+	err = s.WriteMPS(&b)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, `* kiwi solver
+* col_2_ is col[2]
+NAME kiwi
+ROWS
+ N obj
+ G c1
+ L c2
+ E c3
+ E stay1
+ E edit1
+COLUMNS
+ box.right c1 1
+ box.right c2 1
+ box.right edit1 1
+ box.left c1 -1
+ box.left c3 -1
+ box.left stay1 1
+ col_2_ c3 1
+ c2_err obj 2000000
+ c2_err c2 -1
+ stay1_plus obj 1
+ stay1_plus stay1 -1
+ stay1_minus obj 1
+ stay1_minus stay1 1
+ edit1_plus obj 1000000
+ edit1_plus edit1 -1
+ edit1_minus obj 1000000
+ edit1_minus edit1 1
+RHS
+ RHS c1 20
+ RHS c2 500
+ RHS stay1 10
+ RHS edit1 300
+BOUNDS
+ FR BND box.right
+ FR BND box.left
+ FR BND col_2_
+ENDATA
+`, b.String(), "s.WriteMPS(&b)")
+
+	assert.EqualString(t, "v_2x", lpName("2x"), "lpName(\"2x\")")
+	assert.EqualString(t, "v_end", lpName("end"), "lpName(\"end\")")
+	assert.EqualString(t, "v_", lpName(""), "lpName(\"\")")
+}
+
+func TestWriteDOT(t *testing.T) {
+	left, right, width := Var("left"), Var("right"), Var("width")
+	s := NewSolver()
+	s.AddConstraint(right.EqualsExpression(left.AddVariable(width)))
+	s.AddConstraint(width.GreaterThanOrEqualsConstant(100))
+	s.AddConstraint(width.LessThanOrEqualsConstant(50), WithStrength(Medium(2)))
+	s.AddStay(left, WithStrength(WEAK))
+	s.AddEditVariable(right, WithStrength(STRONG))
+	s.SuggestValue(right, 120)
+	s.UpdateVariables()
+
+	var b strings.Builder
+	err := s.WriteDOT(&b)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, `graph kiwi {
+	node [shape=ellipse];
+	v1 [label="left = 20"];
+	v2 [label="width = 100"];
+	v3 [label="right = 120", style=filled, fillcolor=gold];
+	c1 [shape=box, fontsize=10, color=black, label="right == left + width"];
+	c1 -- v1 [color=black];
+	c1 -- v2 [color=black];
+	c1 -- v3 [color=black];
+	c2 [shape=box, fontsize=10, color=black, label="width >= 100"];
+	c2 -- v2 [color=black];
+	c3 [shape=box, fontsize=10, color=orange, label="width <= 50 | medium(2)", style=filled, fillcolor="#ffc0c0", penwidth=2];
+	c3 -- v2 [color=orange];
+	c4 [shape=box, fontsize=10, color=blue, label="left == 0 | weak", style=filled, fillcolor="#ffc0c0", penwidth=2];
+	c4 -- v1 [color=blue];
+}
+`, b.String(), "s.WriteDOT(&b)")
+
+	b.Reset()
+	WriteDOT(&b, []*Constraint{Var(`say "hi"`).EqualsConstant(0)})
+	assert.Equal(t, true, strings.Contains(b.String(), `[label="say \"hi\" = 0"]`), "quoted label: %s", b.String())
+}
+
+func TestBox(t *testing.T) {
+	window, panel, button := NewBox("window"), NewBox("panel"), NewBox("button")
+	assert.EqualString(t, "window.left + window.width + 0", window.Right().String(), "window.Right()")
+	assert.EqualString(t, "window.top + 0.5 * window.height + 0", window.CenterY().String(), "window.CenterY()")
+
+	s := NewSolver()
+	cns := []*Constraint{
+		window.Left.EqualsConstant(0),
+		window.Top.EqualsConstant(0),
+		window.Width.EqualsConstant(400),
+		window.Height.EqualsConstant(300),
+		button.Width.EqualsConstant(80),
+		button.Height.EqualsConstant(20),
+	}
+	cns = append(cns, panel.Inside(window, 10)...)
+	cns = append(cns, panel.Align(window, EdgeLeft|EdgeTop|EdgeBottom, WithStrength(STRONG))...)
+	cns = append(cns, panel.Width.EqualsConstant(200))
+	cns = append(cns, panel.Contains(button)...)
+	cns = append(cns, button.Align(panel, EdgeCenterX|EdgeCenterY)...)
+	assert.Equal(t, nil, s.AddConstraints(cns), "s.AddConstraints(cns)")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 10, panel.Left.Value, "panel.Left")
+	assert.EqualFloat64(t, 280, panel.Height.Value, "panel.Height")
+	assert.EqualFloat64(t, 70, button.Left.Value, "button.Left")
+	assert.EqualFloat64(t, 140, button.Top.Value, "button.Top")
+
+	// A failing constraint set is not added at all
+	other := NewBox("other")
+	same := other.SameSize(button)
+	same = append(same, other.Width.EqualsConstant(100))
+	err := s.AddConstraints(same)
+	assert.Equal(t, true, errors.As(err, &UnsatisfiableConstraint{}), "errors.As(err, &UnsatisfiableConstraint{})")
+	assert.Equal(t, false, s.HasConstraint(same[0]), "s.HasConstraint(same[0])")
+}
+
+func TestParseErrors(t *testing.T) {
+	x, y := Var("x"), Var("y")
+	vars := []*Variable{x, y}
+
+	_, err := ParseExpr("x + * 2")
+	var pe ParseError
+	assert.Equal(t, true, errors.As(err, &pe), "errors.As(err, &pe)")
+	assert.Equal(t, 1, pe.Line, "pe.Line")
+	assert.Equal(t, 5, pe.Column, "pe.Column")
+	assert.EqualString(t, "*", pe.Token, "pe.Token")
+	assert.EqualString(t, "1:5: Syntax Error: expected operand, found *", pe.Error(), "pe.Error()")
+	assert.EqualString(t, "1:5: Syntax Error: expected operand, found *\nx + * 2\n    ^", pe.Caret(), "pe.Caret()")
+
+	// Evaluation errors point at the offending token in the text
+	_, err = ParseConstraint("x + width <= 10", vars)
+	assert.Equal(t, true, errors.As(err, &pe), "errors.As(err, &pe)")
+	assert.Equal(t, 5, pe.Column, "pe.Column")
+	assert.EqualString(t, "1:5: Unknown Variable Name: \"width\"\nx + width <= 10\n    ^^^^^", pe.Caret(), "pe.Caret()")
+
+	_, err = ParseConstraint("2 * (x + 1) * y >= 0", vars)
+	assert.Equal(t, true, errors.As(err, &pe), "errors.As(err, &pe)")
+	assert.Equal(t, 13, pe.Column, "pe.Column")
+	assert.EqualString(t, "*", pe.Token, "pe.Token")
+	assert.Equal(t, false, errors.Is(err, SyntaxError), "errors.Is(err, SyntaxError)")
+
+	// Positions count characters, not bytes, and multi-line input reports the line
+	_, err = ParseExpr("x ≥ 0")
+	assert.Equal(t, true, errors.As(err, &pe), "errors.As(err, &pe)")
+	assert.Equal(t, 3, pe.Column, "pe.Column")
+	assert.EqualString(t, "≥", pe.Token, "pe.Token")
+	_, err = ParseExpr("x +\n\ty )")
+	assert.Equal(t, true, errors.As(err, &pe), "errors.As(err, &pe)")
+	assert.Equal(t, 2, pe.Line, "pe.Line")
+	assert.Equal(t, 4, pe.Column, "pe.Column")
+	assert.EqualString(t, "\ty )", pe.Source, "pe.Source")
+	assert.EqualString(t, "2:4: Syntax Error: expected operator, found )\n\ty )\n\t  ^", pe.Caret(), "pe.Caret()")
+
+	doc := "x >= 0\ny <= x +\nz == 1\n"
+	_, err = ParseConstraints(strings.NewReader(doc), NamesOf(vars))
+	var pes ParseErrors
+	assert.Equal(t, true, errors.As(err, &pes), "errors.As(err, &pes)")
+	assert.EqualString(t, "2:9: Syntax Error: expected operand, found end of input\n3:1: Unknown Variable Name: \"z\"", err.Error(), "err.Error()")
+}
+
+func TestFunctions(t *testing.T) {
+	ns := NewScope(WithAutoCreate())
+
+	cns, err := ParseConstraintIn("-x + 10 >= -(y - 2) * 2", ns)
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "-1 * x + 2 * y + 6 >= 0 | Strength = REQUIRED", cns.String(), "cns.String()")
+
+	expr, err := ParseExpr("w >= max(a, b + 2)")
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "w >= max(a, b + INT(2))", expr.String(), "expr.String()")
+	_, err = expr.NewConstraintIn(ns)
+	assert.NotEqual(t, nil, err, "err")
+
+	count := func(src string) int {
+		t.Helper()
+		cnss, err := ParseConstraints(strings.NewReader(src), ns)
+		assert.Equal(t, nil, err, "%q: err", src)
+		return len(cnss)
+	}
+	assert.Equal(t, 3, count("w >= max(a, b)"), "w >= max(a, b)")
+	assert.Equal(t, 3, count("w <= min(a, b)"), "w <= min(a, b)")
+	assert.Equal(t, 3, count("abs(x - y) <= 5"), "abs(x - y) <= 5")
+	assert.Equal(t, 5, count("w == max(a, b) | strong"), "w == max(a, b) | strong")
+	assert.Equal(t, 1, count("w == max(1, 3, 2) + min(4, 5) + abs(-3)"), "w == max(1, 3, 2) + min(4, 5) + abs(-3)")
+	assert.Equal(t, 7, count("w >= max(a, min(b, c)) | strong"), "w >= max(a, min(b, c)) | strong")
+	assert.Equal(t, 6, count("0 <= max(a, b) <= 100 | strong"), "0 <= max(a, b) <= 100 | strong")
+
+	// A function in a chained comparison is a single auxiliary variable
+	cnss, err := ParseConstraints(strings.NewReader("0 <= max(a, b) <= 100 | strong"), ns)
+	assert.Equal(t, nil, err, "err")
+	auxiliaries := make(map[*Variable]bool)
+	for _, c := range cnss {
+		for _, term := range c.Expression.Terms {
+			if term.Variable.Name == "max(a, b)" {
+				auxiliaries[term.Variable] = true
+			}
+		}
+	}
+	assert.Equal(t, 1, len(auxiliaries), "len(auxiliaries)")
+
+	solve := func(src string) {
+		t.Helper()
+		cnss, err := ParseConstraints(strings.NewReader(src), ns)
+		assert.Equal(t, nil, err, "err")
+		s := NewSolver()
+		for _, c := range cnss {
+			err := s.AddConstraint(c.Constraint)
+			assert.Equal(t, nil, err, "%v: err", c)
+		}
+		s.UpdateVariables()
+	}
+	a, b, w, y := ns.Var("a"), ns.Var("b"), ns.Var("w"), ns.Var("y")
+
+	solve("a == 10\nb == 20\nw == max(a, b) | strong")
+	assert.EqualFloat64(t, 20, w.Value, "w.Value")
+	solve("a == 10\nb == 20\nw == min(a, b) | strong")
+	assert.EqualFloat64(t, 10, w.Value, "w.Value")
+	solve("x == 3\ny == 10\nw == abs(x - y) | strong")
+	assert.EqualFloat64(t, 7, w.Value, "w.Value")
+	solve("x == 3\nabs(x - y) <= 5 | strong\ny == 30 | weak")
+	assert.EqualFloat64(t, 8, y.Value, "y.Value")
+
+	// The pulls are as strong as the comparison that needs them
+	solve("a == 10\nb == 20\nw == max(a, b) | strong\nw == 100 | medium")
+	assert.EqualFloat64(t, 20, w.Value, "w.Value")
+	// A stronger constraint wins from a weaker comparison
+	solve("a == 10\nb == 20\nw <= max(a, b) | medium\nw == 100 | strong")
+	assert.EqualFloat64(t, 100, w.Value, "w.Value")
+	_, err = ParseConstraintIn("w <= max(a, b)", ns)
+	var fe ParseError
+	assert.Equal(t, true, errors.As(err, &fe), "errors.As(err, &fe)")
+	assert.EqualString(t, "max(a, b)", fe.Token, "fe.Token")
+	_, err = ParseConstraintIn("w >= max(a, min(b, c))", ns)
+	assert.Equal(t, true, errors.As(err, &fe), "errors.As(err, &fe)")
+	assert.EqualString(t, "min(b, c)", fe.Token, "fe.Token")
+
+	_, err = ParseConstraintIn("w == avg(a, b)", ns)
+	var pe ParseError
+	assert.Equal(t, true, errors.As(err, &pe), "errors.As(err, &pe)")
+	assert.EqualString(t, "avg", pe.Token, "pe.Token")
+
+	// The Go API always includes the pulls
+	m, group := Max(a.AddConstant(0), b.AddConstant(5))
+	assert.EqualString(t, "max(a + 0, b + 5)", m.Name, "m.Name")
+	assert.Equal(t, 4, len(group), "len(group)")
+	s := NewSolver()
+	s.AddConstraint(a.EqualsConstant(1))
+	s.AddConstraint(b.EqualsConstant(2))
+	for _, c := range group {
+		s.AddConstraint(c)
+	}
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 7, m.Value, "m.Value")
+
+	m, group = Abs(a.AddVariable(b).AddConstant(-10))
+	assert.Equal(t, 4, len(group), "len(group)")
+	for _, c := range group {
+		s.AddConstraint(c)
+	}
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 7, m.Value, "m.Value")
+
+	m, group = Min(a.AddConstant(0), b.AddConstant(0))
+	for _, c := range group {
+		s.AddConstraint(c)
+	}
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 1, m.Value, "m.Value")
+
+	// Only a required constraint can move the variable off the minimum
+	s.AddConstraint(m.EqualsConstant(-5), WithStrength(STRONG))
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 1, m.Value, "m.Value")
+}
+
+func TestStrengthAnnotations(t *testing.T) {
+	x, y, w := Var("x"), Var("y"), Var("w")
+	vars := []*Variable{x, y, w}
+
+	annotated := map[string]Strength{
+		"x + y <= 10 | strong":               STRONG,
+		"w == 100 @ medium(250)":             Medium(250),
+		"w == 100 !weak":                     WEAK,
+		"x >= 0 | Strength = Weak(1.5)":      Weak(1.5),
+		"x >= 0 | REQUIRED":                  REQUIRED,
+		"x >= 0 | optional":                  OPTIONAL,
+		"x >= 0 | 12.5":                      Strength(12.5),
+		"x >= 0":                             REQUIRED,
+		"x >= y | strong(2000)":              Strong(1000),
+		"x - 2 * y == w | Strength = MEDIUM": MEDIUM,
+	}
+	for src, strength := range annotated {
+		cns, err := ParseConstraint(src, vars)
+		assert.Equal(t, nil, err, "%q: err", src)
+		if err == nil {
+			assert.Equal(t, strength, cns.Strength, "%q: cns.Strength", src)
+		}
+	}
+
+	// The annotation takes precedence over the option
+	cns, err := ParseConstraint("x == 10 | weak", vars, WithStrength(STRONG))
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, WEAK, cns.Strength, "cns.Strength")
+
+	// Constraints round trip through their String method
+	for _, c := range []*Constraint{
+		NewConstraint(Expression{[]Term{{x, 0.125}, {y, -1}}, 7}, EQ, WithStrength(Strong(123))),
+		NewConstraint(Expression{[]Term{{w, 1}}, -0.5}, LE, WithStrength(WEAK)),
+		NewConstraint(Expression{[]Term{{x, 2}, {y, 1e-3}}, 1e6}, GE),
+		NewConstraint(Expression{[]Term{{y, -1}}, 0}, GE, WithStrength(Medium(2.5))),
+	} {
+		text := c.String()
+		cns, err := ParseConstraint(text, vars)
+		assert.Equal(t, nil, err, "%q: err", text)
+		if err == nil {
+			assert.EqualString(t, text, cns.String(), "cns.String()")
+		}
+	}
+
+	for _, src := range []string{"x == 1 | strongest", "x == 1 | strong(", "x == 1 |", "x == 1 | required(3)"} {
+		_, err := ParseExpr(src)
+		assert.Equal(t, true, errors.Is(err, SyntaxError), "%q: errors.Is(err, SyntaxError)", src)
+	}
+}
+
 func TestSimple0(t *testing.T) {
 	solver := NewSolver()
 	x := Var("x")
@@ -492,6 +1355,46 @@ func TestDumpingSolver(t *testing.T) {
 	}
 }
 
+// Test inspecting the constraints, edit variables and variables of a solver.
+func TestIntrospection(t *testing.T) {
+	x, y, z := Var("x"), Var("y"), Var("z")
+
+	s := NewSolver()
+	c1 := x.AddConstant(10).LessThanOrEqualsVariable(y) // x + 10 <= y
+	c2 := y.LessThanOrEqualsConstant(100)               // y <= 100
+	s.AddConstraint(c1)
+	s.AddStay(z, WithStrength(WEAK))
+	s.AddEditVariable(y, WithStrength(MEDIUM))
+	s.AddEditVariable(x)
+	s.AddConstraint(c2)
+	s.SuggestValue(y, 50)
+
+	cns := s.Constraints()
+	assert.Equal(t, 2, len(cns), "len(cns)")
+	assert.Equal(t, c1, cns[0], "cns[0]")
+	assert.Equal(t, c2, cns[1], "cns[1]")
+
+	edits := s.EditVariables()
+	assert.Equal(t, 2, len(edits), "len(edits)")
+	assert.Equal(t, EditVariable{y, MEDIUM, 50}, edits[0], "edits[0]")
+	assert.Equal(t, EditVariable{x, STRONG, 0}, edits[1], "edits[1]")
+
+	vars := s.Variables()
+	assert.Equal(t, 3, len(vars), "len(vars)")
+	assert.Equal(t, x, vars[0], "vars[0]")
+	assert.Equal(t, y, vars[1], "vars[1]")
+	assert.Equal(t, z, vars[2], "vars[2]")
+
+	basic := 0
+	for _, v := range vars {
+		if s.IsBasic(v) {
+			basic++
+		}
+	}
+	assert.Equal(t, true, basic > 0, "basic > 0")
+	assert.Equal(t, false, s.IsBasic(Var("w")), "s.IsBasic(w)")
+}
+
 // Test that we properly handle infeasible constraints.
 func TestHandlingInfeasibleConstraints(t *testing.T) {
 	// We use the example of the cassowary paper to generate an infeasible
@@ -528,6 +1431,229 @@ func TestHandlingInfeasibleConstraints(t *testing.T) {
 	assert.EqualFloat64(t, 100, xr.Value, "xr.Value")
 }
 
+// Test changing the constant of constraints already added to the solver.
+func TestSetConstant(t *testing.T) {
+	x, y := Var("x"), Var("y")
+
+	s := NewSolver()
+
+	margin := x.GreaterThanOrEqualsConstant(12)     // x >= 12
+	width := y.EqualsExpression(x.AddConstant(100)) // y == x + 100
+	limit := y.LessThanOrEqualsConstant(200)        // y <= 200
+	prefer := x.EqualsConstant(0)                   // x == 0 | WEAK
+	for _, c := range []*Constraint{margin, width, limit} {
+		err := s.AddConstraint(c)
+		assert.Equal(t, nil, err, "err")
+	}
+	err := s.AddConstraint(prefer, WithStrength(WEAK))
+	assert.Equal(t, nil, err, "err")
+
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 12, x.Value, "x.Value")
+	assert.EqualFloat64(t, 112, y.Value, "y.Value")
+
+	err = s.SetConstant(margin, -16) // x >= 16
+	assert.Equal(t, nil, err, "err")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 16, x.Value, "x.Value")
+	assert.EqualFloat64(t, 116, y.Value, "y.Value")
+
+	err = s.SetConstant(width, 50) // x + 50 - y == 0
+	assert.Equal(t, nil, err, "err")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 16, x.Value, "x.Value")
+	assert.EqualFloat64(t, 66, y.Value, "y.Value")
+
+	err = s.SetConstant(prefer, -120) // x == 120 | WEAK
+	assert.Equal(t, nil, err, "err")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 120, x.Value, "x.Value")
+	assert.EqualFloat64(t, 170, y.Value, "y.Value")
+
+	err = s.SetConstant(limit, -150) // y <= 150
+	assert.Equal(t, nil, err, "err")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 100, x.Value, "x.Value")
+	assert.EqualFloat64(t, 150, y.Value, "y.Value")
+
+	err = s.SetConstant(y.EqualsConstant(0), 0)
+	_, ok := err.(UnknownConstraint)
+	assert.Equal(t, true, ok, "_, ok := err.(UnknownConstraint); ok")
+}
+
+// Test that stays follow their variable after an edit.
+func TestUpdateStays(t *testing.T) {
+	x, y := Var("x", 10), Var("y", 20)
+
+	s := NewSolver()
+	s.AddStay(x, WithStrength(WEAK))
+	s.AddStay(y, WithStrength(WEAK))
+	s.AddConstraint(x.AddConstant(10).LessThanOrEqualsVariable(y)) // x + 10 <= y
+
+	s.AddEditVariable(y)
+	s.SuggestValue(y, 5)
+	s.UpdateVariables()
+	assert.EqualFloat64(t, -5, x.Value, "x.Value")
+	assert.EqualFloat64(t, 5, y.Value, "y.Value")
+
+	err := s.RemoveEditVariable(y)
+	assert.Equal(t, nil, err, "err")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, -5, x.Value, "x.Value")
+	assert.EqualFloat64(t, 5, y.Value, "y.Value")
+}
+
+// Test suggesting values for multiple edit variables at once.
+func TestSuggestValues(t *testing.T) {
+	xm, xl, xr := Var("xm"), Var("xl"), Var("xr")
+
+	s := NewSolver()
+
+	s.AddEditVariable(xm, WithStrength(STRONG))
+	s.AddEditVariable(xl, WithStrength(WEAK))
+	s.AddEditVariable(xr, WithStrength(WEAK))
+
+	s.AddConstraint(xm.Multiply(2).EqualsExpression(xl.AddVariable(xr))) // 2 * xm == xl + xr
+	s.AddConstraint(xl.AddConstant(20).LessThanOrEqualsVariable(xr))     // xl + 20 <= xr
+	s.AddConstraint(xl.GreaterThanOrEqualsConstant(-10))                 // xl >= -10
+	s.AddConstraint(xr.LessThanOrEqualsConstant(100))                    // xr <= 100
+
+	err := s.SuggestValues(map[*Variable]float64{xm: 40, xr: 50, xl: 30})
+	assert.Equal(t, nil, err, "err")
+	err = s.SuggestValues(map[*Variable]float64{xm: 90})
+	assert.Equal(t, nil, err, "err")
+
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 80, xl.Value, "xl.Value")
+	assert.EqualFloat64(t, 90, xm.Value, "xm.Value")
+	assert.EqualFloat64(t, 100, xr.Value, "xr.Value")
+
+	err = s.SuggestValues(map[*Variable]float64{xm: 50, Var("foo"): 10})
+	_, ok := err.(UnknownEditVariable)
+	assert.Equal(t, true, ok, "_, ok := err.(UnknownEditVariable); ok")
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 90, xm.Value, "xm.Value")
+}
+
+// Test that stays that fail to update are reported and removed.
+func TestStayErrors(t *testing.T) {
+	x, y := Var("x", 0), Var("y", 0)
+
+	s := NewSolver()
+	err := s.AddStay(x, WithStrength(REQUIRED))
+	assert.Equal(t, nil, err, "err")
+	err = s.AddStay(y, WithStrength(WEAK))
+	assert.Equal(t, nil, err, "err")
+	err = s.AddConstraint(x.LessThanOrEqualsConstant(5)) // x <= 5
+	assert.Equal(t, nil, err, "err")
+
+	stays := s.Stays()
+	assert.Equal(t, 2, len(stays), "len(stays)")
+	assert.Equal(t, Stay{x, REQUIRED, 0}, stays[0], "stays[0]")
+	assert.Equal(t, Stay{y, WEAK, 0}, stays[1], "stays[1]")
+
+	x.Value, y.Value = 10, 20
+	err = s.UpdateStays()
+	errs, ok := err.(StayErrors)
+	assert.Equal(t, true, ok, "_, ok := err.(StayErrors); ok")
+	assert.Equal(t, 1, len(errs), "len(errs)")
+	assert.Equal(t, x, errs[0].Variable, "errs[0].Variable")
+	assert.Equal(t, false, s.HasStay(x), "s.HasStay(x)")
+
+	stays = s.Stays()
+	assert.Equal(t, 1, len(stays), "len(stays)")
+	assert.Equal(t, Stay{y, WEAK, 20}, stays[0], "stays[0]")
+
+	s.UpdateVariables()
+	assert.Equal(t, true, x.Value <= 5, "x.Value <= 5")
+	assert.EqualFloat64(t, 20, y.Value, "y.Value")
+}
+
+// Test editing a group of variables in an edit session.
+func TestEditSession(t *testing.T) {
+	x, y := Var("x", 10), Var("y", 20)
+
+	s := NewSolver()
+	s.AddStay(x, WithStrength(WEAK))
+	s.AddStay(y, WithStrength(WEAK))
+	s.AddConstraint(x.AddConstant(10).LessThanOrEqualsVariable(y)) // x + 10 <= y
+
+	e, err := s.BeginEdit([]*Variable{x, y}, WithStrength(STRONG))
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, true, s.HasEditVariable(x), "s.HasEditVariable(x)")
+	assert.Equal(t, true, s.HasEditVariable(y), "s.HasEditVariable(y)")
+
+	err = e.SuggestValues(map[*Variable]float64{x: 50, y: 55})
+	assert.Equal(t, nil, err, "err")
+	err = e.SuggestValue(Var("z"), 1)
+	_, ok := err.(UnknownEditVariable)
+	assert.Equal(t, true, ok, "_, ok := err.(UnknownEditVariable); ok")
+
+	err = e.SuggestValue(y, 30)
+	assert.Equal(t, nil, err, "err")
+
+	err = e.End()
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, false, s.HasEditVariable(x), "s.HasEditVariable(x)")
+	assert.Equal(t, false, s.HasEditVariable(y), "s.HasEditVariable(y)")
+	assert.Equal(t, nil, e.End(), "e.End()")
+
+	s.UpdateVariables()
+	assert.EqualFloat64(t, 20, x.Value, "x.Value")
+	assert.EqualFloat64(t, 30, y.Value, "y.Value")
+
+	err = s.AddEditVariable(y)
+	assert.Equal(t, nil, err, "err")
+	_, err = s.BeginEdit([]*Variable{x, y})
+	_, ok = err.(DuplicateEditVariable)
+	assert.Equal(t, true, ok, "_, ok := err.(DuplicateEditVariable); ok")
+	assert.Equal(t, false, s.HasEditVariable(x), "s.HasEditVariable(x)")
+}
+
+// benchmarkSolver creates a solver with a chain of n points along x and y
+// all of which are edit variables.
+func benchmarkSolver(n int) (*Solver, []*Variable) {
+	s := NewSolver()
+	vars := make([]*Variable, 0, 2*n)
+	for i := 0; i < n; i++ {
+		x, y := Var("x"), Var("y")
+		s.AddConstraint(x.GreaterThanOrEqualsConstant(0))
+		s.AddConstraint(y.GreaterThanOrEqualsConstant(0))
+		s.AddConstraint(x.LessThanOrEqualsConstant(1000))
+		s.AddConstraint(y.LessThanOrEqualsConstant(1000))
+		if i > 0 {
+			s.AddConstraint(vars[len(vars)-2].AddConstant(1).LessThanOrEqualsVariable(x))
+			s.AddConstraint(vars[len(vars)-1].AddConstant(1).LessThanOrEqualsVariable(y))
+		}
+		s.AddEditVariable(x, WithStrength(MEDIUM))
+		s.AddEditVariable(y, WithStrength(MEDIUM))
+		vars = append(vars, x, y)
+	}
+	return s, vars
+}
+
+func BenchmarkSuggestValue(b *testing.B) {
+	s, vars := benchmarkSolver(16)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, v := range vars {
+			s.SuggestValue(v, float64((i+j)%1000))
+		}
+	}
+}
+
+func BenchmarkSuggestValues(b *testing.B) {
+	s, vars := benchmarkSolver(16)
+	values := make(map[*Variable]float64, len(vars))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, v := range vars {
+			values[v] = float64((i + j) % 1000)
+		}
+		s.SuggestValues(values)
+	}
+}
+
 var assert = struct {
 	Equal        func(t *testing.T, exp, got interface{}, msg string, info ...interface{})
 	NotEqual     func(t *testing.T, exp, got interface{}, msg string, info ...interface{})
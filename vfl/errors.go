@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package vfl
+
+import (
+	"fmt"
+
+	"github.com/reactivego/kiwi"
+)
+
+const MissingSuperview = kiwi.Error("Missing Superview")
+
+type UnknownName struct{ Name string }
+
+func (e UnknownName) Error() string {
+	return fmt.Sprintf("Unknown Name: %q", e.Name)
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package vfl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/reactivego/kiwi"
+)
+
+func solve(t *testing.T, cns []*kiwi.Constraint, extra ...*kiwi.Constraint) {
+	t.Helper()
+	s := kiwi.NewSolver()
+	for _, c := range append(cns, extra...) {
+		if err := s.AddConstraint(c); err != nil {
+			t.Fatalf("%v: %v", c, err)
+		}
+	}
+	s.UpdateVariables()
+}
+
+func TestParse(t *testing.T) {
+	super, a, b := kiwi.NewBox("super"), kiwi.NewBox("a"), kiwi.NewBox("b")
+	views := map[string]*kiwi.Box{"a": a, "b": b}
+
+	cns, err := Parse("H:|-[a(>=50)]-8-[b(==a)]-|", views, WithSuperview(super))
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, 5, len(cns), "len(cns)")
+	assert.EqualString(t, "a.width >= 50", kiwi.FormatConstraint(cns[0]), "cns[0]")
+	assert.EqualString(t, "a.left == super.left + 20", kiwi.FormatConstraint(cns[1]), "cns[1]")
+	assert.EqualString(t, "b.width == a.width", kiwi.FormatConstraint(cns[2], kiwi.WithLeft(b.Width)), "cns[2]")
+	solve(t, cns, super.Left.EqualsConstant(0), super.Width.EqualsConstant(300))
+	assert.EqualFloat64(t, 20, a.Left.Value, "a.left")
+	assert.EqualFloat64(t, 126, a.Width.Value, "a.width")
+	assert.EqualFloat64(t, 154, b.Left.Value, "b.left")
+	assert.EqualFloat64(t, 126, b.Width.Value, "b.width")
+
+	// Vertical, empty connections, metrics and priorities
+	cns, err = Parse("V:[a(height@750)][b(>=100,<=200)]-(>=gap@250)-|", views,
+		WithSuperview(super), WithMetrics(map[string]float64{"height": 40, "gap": 10}))
+	assert.Equal(t, nil, err, "err")
+	assert.Equal(t, Strength(750), cns[0].Strength, "cns[0].Strength")
+	assert.EqualString(t, "b.top == a.top + a.height", kiwi.FormatConstraint(cns[3]), "cns[3]")
+	assert.EqualString(t, "super.top >= -super.height + b.top + b.height + 10 | medium", kiwi.FormatConstraint(cns[4], kiwi.WithLeft(super.Top)), "cns[4]")
+	solve(t, cns, a.Top.EqualsConstant(0), super.Top.EqualsConstant(0), super.Height.EqualsConstant(100))
+	assert.EqualFloat64(t, 40, a.Height.Value, "a.height")
+	assert.EqualFloat64(t, 100, b.Height.Value, "b.height")
+
+	// A standard spacing without superview and custom spacings
+	cns, err = Parse("[a]-[b]", views, WithSpacing(4, 16))
+	assert.Equal(t, nil, err, "err")
+	assert.EqualString(t, "b.left == a.left + a.width + 4", kiwi.FormatConstraint(cns[0]), "cns[0]")
+
+	// The views are boxes, so they combine with the relations of kiwi.Box
+	cns, err = Parse("H:|-[a]-[b(==a)]-|", views, WithSuperview(super))
+	assert.Equal(t, nil, err, "err")
+	cns = append(cns, a.Align(super, kiwi.EdgeTop|kiwi.EdgeBottom)...)
+	cns = append(cns, b.SameSize(a)...)
+	solve(t, cns, super.Left.EqualsConstant(0), super.Top.EqualsConstant(0), super.Width.EqualsConstant(208), super.Height.EqualsConstant(50))
+	assert.EqualFloat64(t, 80, b.Width.Value, "b.width")
+	assert.EqualFloat64(t, 50, b.Height.Value, "b.height")
+}
+
+func TestStrength(t *testing.T) {
+	assert.Equal(t, kiwi.REQUIRED, Strength(1000), "Strength(1000)")
+	assert.Equal(t, kiwi.Strong(999), Strength(999), "Strength(999)")
+	assert.Equal(t, kiwi.STRONG, Strength(500), "Strength(500)")
+	assert.Equal(t, kiwi.MEDIUM, Strength(250), "Strength(250)")
+	assert.Equal(t, kiwi.WEAK, Strength(1), "Strength(1)")
+	previous := kiwi.OPTIONAL
+	for priority := 1.0; priority <= 1000; priority++ {
+		if Strength(priority) <= previous {
+			t.Fatalf("Strength(%v) = %v not greater than %v", priority, Strength(priority), previous)
+		}
+		previous = Strength(priority)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	views := map[string]*kiwi.Box{"a": kiwi.NewBox("a")}
+	for _, test := range []struct {
+		format, caret string
+		err           error
+	}{
+		{"H:|[a]", "1:3: Missing Superview\nH:|[a]\n  ^", MissingSuperview},
+		{"[a]-[c]", "1:6: Unknown Name: \"c\"\n[a]-[c]\n     ^", UnknownName{"c"}},
+		{"[a(>=gap)]", "1:6: Unknown Name: \"gap\"\n[a(>=gap)]\n     ^^^", UnknownName{"gap"}},
+		{"[a(50]", "1:6: Syntax Error: expected , or ), found ]\n[a(50]\n     ^", kiwi.SyntaxError},
+		{"[a]-8[a]", "1:6: Syntax Error: expected -, found [\n[a]-8[a]\n     ^", kiwi.SyntaxError},
+		{"[a", "1:3: Syntax Error: expected ], found end of format\n[a\n  ^", kiwi.SyntaxError},
+	} {
+		_, err := Parse(test.format, views)
+		var pe kiwi.ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%q: expected a parse error, got %v", test.format, err)
+			continue
+		}
+		assert.EqualString(t, test.caret, pe.Caret(), "%q: pe.Caret()", test.format)
+		assert.Equal(t, true, errors.Is(err, test.err), "%q: errors.Is(err, %v)", test.format, test.err)
+	}
+	_, err := Parse("[a]-[a]-", views)
+	assert.Equal(t, true, strings.HasSuffix(err.Error(), "found end of format"), "err: %v", err)
+}
+
+var assert = struct {
+	Equal        func(t *testing.T, exp, got interface{}, msg string, info ...interface{})
+	EqualFloat64 func(t *testing.T, exp, got float64, msg string, info ...interface{})
+	EqualString  func(t *testing.T, exp, got string, msg string, info ...interface{})
+}{
+	Equal: func(t *testing.T, exp, got interface{}, msg string, info ...interface{}) {
+		t.Helper()
+		if exp != got {
+			t.Errorf(msg+" expected %#v got %#v", append(append(info, exp), got)...)
+		}
+	},
+	EqualFloat64: func(t *testing.T, exp, got float64, msg string, info ...interface{}) {
+		t.Helper()
+		if !kiwi.NearZero(got - exp) {
+			t.Errorf(msg+" expected %g got %g", append(append(info, exp), got)...)
+		}
+	},
+	EqualString: func(t *testing.T, exp, got string, msg string, info ...interface{}) {
+		t.Helper()
+		if exp != got {
+			t.Errorf(msg+" expected %#q got %#q", append(append(info, exp), got)...)
+		}
+	},
+}
@@ -0,0 +1,469 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package vfl parses the Visual Format Language of Apple's Auto Layout into
+kiwi constraints.
+
+A format string describes a row or column of views and the spacing
+between them, e.g.
+
+	H:|-[a(>=50)]-8-[b(==a)]-|
+
+lines up the views a and b horizontally inside the superview, with the
+standard spacing to the superview, a spacing of 8 between a and b, a at
+least 50 wide and b as wide as a. The grammar is:
+
+	Format     = [ ( "H" | "V" ) ":" ] [ "|" Connection ] View { Connection View } [ Connection "|" ] .
+	View       = "[" name [ "(" Predicate { "," Predicate } ")" ] "]" .
+	Connection = "-" [ Simple | "(" Predicate { "," Predicate } ")" ] "-" | "-" | "" .
+	Simple     = number | name .
+	Predicate  = [ "==" | "<=" | ">=" ] ( number | name ) [ "@" ( number | name ) ] .
+
+A single "-" stands for the standard spacing and an empty connection for
+no spacing at all. Names in predicates refer to metrics, or for the size
+of a view to other views. Priorities range from 1 to 1000 and are mapped
+onto kiwi strengths by Strength.
+
+The views are kiwi boxes, so the constraints of a format can be combined
+with those of the kiwi layout package and the relations of kiwi.Box.
+*/
+package vfl
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/reactivego/kiwi"
+)
+
+type parser struct {
+	format    string
+	offset    int
+	views     map[string]*kiwi.Box
+	superview *kiwi.Box
+	metrics   map[string]float64
+	sibling   float64
+	margin    float64
+	vertical  bool
+	cns       []*kiwi.Constraint
+}
+
+// position and size return the variables of the box for the orientation.
+func (p *parser) position(b *kiwi.Box) *kiwi.Variable {
+	if p.vertical {
+		return b.Top
+	}
+	return b.Left
+}
+
+func (p *parser) size(b *kiwi.Box) *kiwi.Variable {
+	if p.vertical {
+		return b.Height
+	}
+	return b.Width
+}
+
+type Option func(*parser)
+
+// WithSuperview is an option to set the view that `|` in a format refers to.
+func WithSuperview(superview *kiwi.Box) Option {
+	return func(p *parser) {
+		p.superview = superview
+	}
+}
+
+// WithMetrics is an option to set the values of names used in predicates.
+func WithMetrics(metrics map[string]float64) Option {
+	return func(p *parser) {
+		p.metrics = metrics
+	}
+}
+
+/*
+WithSpacing is an option to set the standard spacing between sibling
+views and between a view and its superview. The defaults are 8 and 20.
+*/
+func WithSpacing(sibling, superview float64) Option {
+	return func(p *parser) {
+		p.sibling, p.margin = sibling, superview
+	}
+}
+
+/*
+Strength maps an Auto Layout priority onto a kiwi strength, keeping the
+order of priorities. A priority of 1000 or more is required, priorities
+from 500 are strong, from 250 medium and below that weak, with the
+weight of the strength growing with the priority.
+*/
+func Strength(priority float64) kiwi.Strength {
+	switch {
+	case priority >= 1000:
+		return kiwi.REQUIRED
+	case priority >= 500:
+		return kiwi.Strong(1 + (priority-500)*2)
+	case priority >= 250:
+		return kiwi.Medium(1 + (priority-250)*4)
+	default:
+		return kiwi.Weak(1 + (priority-1)*4)
+	}
+}
+
+/*
+Parse parses the format string into constraints between the views. The
+views are referred to by their name in the map.
+
+Returns
+
+	kiwi.ParseError
+The format is invalid or refers to an unknown view or metric, see
+UnknownName, or uses `|` without a superview, see MissingSuperview.
+*/
+func Parse(format string, views map[string]*kiwi.Box, options ...Option) ([]*kiwi.Constraint, error) {
+	p := &parser{format: format, views: views, sibling: 8, margin: 20}
+	for _, option := range options {
+		option(p)
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.cns, nil
+}
+
+type predicate struct {
+	op       kiwi.Operator
+	view     *kiwi.Box
+	constant float64
+	strength kiwi.Strength
+}
+
+/*
+connection is the spacing between two views. Without predicates the
+spacing is the standard spacing, unless the connection is empty.
+*/
+type connection struct {
+	empty      bool
+	predicates []predicate
+}
+
+func (p *parser) parse() error {
+	if strings.HasPrefix(p.rest(), "H:") {
+		p.offset += 2
+	} else if strings.HasPrefix(p.rest(), "V:") {
+		p.vertical = true
+		p.offset += 2
+	}
+	var previous *kiwi.Box
+	if p.peek() == '|' {
+		if err := p.expectSuperview(); err != nil {
+			return err
+		}
+		p.offset++
+		c, err := p.parseConnection()
+		if err != nil {
+			return err
+		}
+		view, err := p.parseView()
+		if err != nil {
+			return err
+		}
+		p.connect(nil, c, view)
+		previous = view
+	} else {
+		view, err := p.parseView()
+		if err != nil {
+			return err
+		}
+		previous = view
+	}
+	for p.skip(); p.offset < len(p.format); p.skip() {
+		c, err := p.parseConnection()
+		if err != nil {
+			return err
+		}
+		if p.peek() == '|' {
+			if err := p.expectSuperview(); err != nil {
+				return err
+			}
+			p.offset++
+			p.connect(previous, c, nil)
+			if p.skip(); p.offset < len(p.format) {
+				return p.errorf(p.offset, p.token(), "expected end of format", nil)
+			}
+			return nil
+		}
+		view, err := p.parseView()
+		if err != nil {
+			return err
+		}
+		p.connect(previous, c, view)
+		previous = view
+	}
+	return nil
+}
+
+/*
+connect adds the constraints for the connection between the views. A nil
+view stands for the edge of the superview.
+*/
+func (p *parser) connect(first *kiwi.Box, c connection, second *kiwi.Box) {
+	// Spacing is the position of the second minus the end of the first.
+	var spacing kiwi.Expression
+	if first == nil {
+		spacing = p.position(p.superview).Negate().AddVariable(p.position(second))
+	} else {
+		end := p.position(first).AddVariable(p.size(first))
+		if second == nil {
+			spacing = p.position(p.superview).AddVariable(p.size(p.superview))
+		} else {
+			spacing = p.position(second).AddConstant(0)
+		}
+		spacing = spacing.AddExpression(end.Negate())
+	}
+	predicates := c.predicates
+	if !c.empty && len(predicates) == 0 {
+		standard := p.sibling
+		if first == nil || second == nil {
+			standard = p.margin
+		}
+		predicates = []predicate{{op: kiwi.EQ, constant: standard, strength: kiwi.REQUIRED}}
+	}
+	if c.empty {
+		predicates = []predicate{{op: kiwi.EQ, strength: kiwi.REQUIRED}}
+	}
+	for _, pr := range predicates {
+		p.add(spacing, pr)
+	}
+}
+
+// add adds the constraint `lhs op rhs` for the predicate.
+func (p *parser) add(lhs kiwi.Expression, pr predicate) {
+	// Copy the terms, as constraints reduce their terms in place.
+	expr := kiwi.Expression{Constant: -pr.constant}.AddExpression(lhs)
+	if pr.view != nil {
+		expr = expr.AddTerm(p.size(pr.view).Negate())
+	}
+	p.cns = append(p.cns, kiwi.NewConstraint(expr, pr.op, kiwi.WithStrength(pr.strength)))
+}
+
+func (p *parser) parseView() (*kiwi.Box, error) {
+	p.skip()
+	if p.peek() != '[' {
+		return nil, p.errorf(p.offset, p.token(), "expected [", nil)
+	}
+	p.offset++
+	p.skip()
+	namePos := p.offset
+	name := p.name()
+	if name == "" {
+		return nil, p.errorf(p.offset, p.token(), "expected view name", nil)
+	}
+	view, present := p.views[name]
+	if !present {
+		return nil, p.errorf(namePos, name, "", UnknownName{name})
+	}
+	p.skip()
+	if p.peek() == '(' {
+		predicates, err := p.parsePredicates(true)
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range predicates {
+			p.add(p.size(view).AddConstant(0), pr)
+		}
+	}
+	p.skip()
+	if p.peek() != ']' {
+		return nil, p.errorf(p.offset, p.token(), "expected ]", nil)
+	}
+	p.offset++
+	return view, nil
+}
+
+func (p *parser) parseConnection() (connection, error) {
+	p.skip()
+	if p.peek() != '-' {
+		if p.peek() == '[' || p.peek() == '|' {
+			return connection{empty: true}, nil
+		}
+		return connection{}, p.errorf(p.offset, p.token(), "expected connection", nil)
+	}
+	p.offset++
+	p.skip()
+	if p.peek() == '[' || p.peek() == '|' {
+		return connection{}, nil
+	}
+	var c connection
+	if p.peek() == '(' {
+		predicates, err := p.parsePredicates(false)
+		if err != nil {
+			return c, err
+		}
+		c.predicates = predicates
+	} else {
+		value, err := p.constant()
+		if err != nil {
+			return c, err
+		}
+		c.predicates = []predicate{{op: kiwi.EQ, constant: value, strength: kiwi.REQUIRED}}
+	}
+	p.skip()
+	if p.peek() != '-' {
+		return c, p.errorf(p.offset, p.token(), "expected -", nil)
+	}
+	p.offset++
+	return c, nil
+}
+
+/*
+parsePredicates parses a parenthesized list of predicates. Only the
+predicates of a view can refer to other views.
+*/
+func (p *parser) parsePredicates(views bool) ([]predicate, error) {
+	p.offset++
+	var predicates []predicate
+	for {
+		p.skip()
+		pr := predicate{op: kiwi.EQ, strength: kiwi.REQUIRED}
+		for _, op := range []kiwi.Operator{kiwi.EQ, kiwi.LE, kiwi.GE} {
+			if strings.HasPrefix(p.rest(), op.String()) {
+				pr.op = op
+				p.offset += 2
+				p.skip()
+				break
+			}
+		}
+		if name := p.peekName(); views && name != "" && p.views[name] != nil {
+			if _, metric := p.metrics[name]; !metric {
+				pr.view = p.views[p.name()]
+			}
+		}
+		if pr.view == nil {
+			value, err := p.constant()
+			if err != nil {
+				return nil, err
+			}
+			pr.constant = value
+		}
+		p.skip()
+		if p.peek() == '@' {
+			p.offset++
+			p.skip()
+			priority, err := p.constant()
+			if err != nil {
+				return nil, err
+			}
+			pr.strength = Strength(priority)
+		}
+		predicates = append(predicates, pr)
+		p.skip()
+		switch p.peek() {
+		case ',':
+			p.offset++
+			continue
+		case ')':
+			p.offset++
+			return predicates, nil
+		}
+		return nil, p.errorf(p.offset, p.token(), "expected , or )", nil)
+	}
+}
+
+// constant parses a number or the name of a metric.
+func (p *parser) constant() (float64, error) {
+	pos := p.offset
+	if name := p.name(); name != "" {
+		value, present := p.metrics[name]
+		if !present {
+			return 0, p.errorf(pos, name, "", UnknownName{name})
+		}
+		return value, nil
+	}
+	end := pos
+	if end < len(p.format) && (p.format[end] == '-' || p.format[end] == '+') {
+		end++
+	}
+	for end < len(p.format) && (isDigit(p.format[end]) || p.format[end] == '.') {
+		end++
+	}
+	value, err := strconv.ParseFloat(p.format[pos:end], 64)
+	if err != nil {
+		return 0, p.errorf(pos, p.token(), "expected number or metric", nil)
+	}
+	p.offset = end
+	return value, nil
+}
+
+func (p *parser) expectSuperview() error {
+	if p.superview == nil {
+		return p.errorf(p.offset, "|", "", MissingSuperview)
+	}
+	return nil
+}
+
+func (p *parser) rest() string {
+	return p.format[p.offset:]
+}
+
+func (p *parser) peek() byte {
+	if p.offset < len(p.format) {
+		return p.format[p.offset]
+	}
+	return 0
+}
+
+func (p *parser) skip() {
+	for p.offset < len(p.format) && (p.format[p.offset] == ' ' || p.format[p.offset] == '\t') {
+		p.offset++
+	}
+}
+
+func (p *parser) peekName() string {
+	end := p.offset
+	for end < len(p.format) && (isLetter(p.format[end]) || (end > p.offset && isDigit(p.format[end]))) {
+		end++
+	}
+	return p.format[p.offset:end]
+}
+
+func (p *parser) name() string {
+	name := p.peekName()
+	p.offset += len(name)
+	return name
+}
+
+// token returns the character at the offset, empty at the end.
+func (p *parser) token() string {
+	if p.offset >= len(p.format) {
+		return ""
+	}
+	_, w := utf8.DecodeRuneInString(p.rest())
+	return p.format[p.offset : p.offset+w]
+}
+
+func (p *parser) errorf(offset int, token, msg string, err error) error {
+	found := token
+	if found == "" {
+		found = "end of format"
+	}
+	if msg != "" {
+		msg += ", found " + found
+	}
+	return kiwi.ParseError{
+		Source: p.format,
+		Line:   1,
+		Column: 1 + utf8.RuneCountInString(p.format[:offset]),
+		Offset: offset,
+		Token:  token,
+		Msg:    msg,
+		Err:    err,
+	}
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}